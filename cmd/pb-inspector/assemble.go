@@ -0,0 +1,35 @@
+package main
+
+import (
+	inspector "github.com/detailyang/pb-inspector-go/protobuf-inspector"
+	"github.com/urfave/cli"
+)
+
+var assembleCommand = cli.Command{
+	Name:      "assemble",
+	Usage:     "assemble the protoscope-like text of --output scope to protobuf binary",
+	ArgsUsage: "<file>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "output-type",
+			Value: "binary",
+			Usage: "Specify the output type (binary, hex or base64)",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() == 0 {
+			return cli.ShowCommandHelp(c, c.Command.Name)
+		}
+
+		src, err := readRaw(c.Args().First(), "binary", "none")
+		if err != nil {
+			return err
+		}
+
+		raw, err := inspector.NewInspector().Assemble(src)
+		if err != nil {
+			return err
+		}
+		return writeRaw(raw, c.String("output-type"))
+	},
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	inspector "github.com/detailyang/pb-inspector-go/protobuf-inspector"
+	"github.com/urfave/cli"
+)
+
+var compatCommand = cli.Command{
+	Name:  "compat",
+	Usage: "check that a new version of the schema can decode the messages of the old one and vice versa",
+	Flags: []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "old-pb-file",
+			Usage: "Specify the .proto file of the old schema (repeatable)",
+		},
+		cli.StringFlag{
+			Name:  "old-pb-dir",
+			Usage: "Specify the directory of the old schema",
+		},
+		cli.StringSliceFlag{
+			Name:  "new-pb-file",
+			Usage: "Specify the .proto file of the new schema (repeatable)",
+		},
+		cli.StringFlag{
+			Name:  "new-pb-dir",
+			Usage: "Specify the directory of the new schema",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		output := c.GlobalString("output")
+		if output != "text" && output != "json" {
+			return errors.New("compat supports text and json output")
+		}
+
+		before, err := readDefinition(c.String("old-pb-dir"), c.StringSlice("old-pb-file"))
+		if err != nil {
+			return err
+		}
+		after, err := readDefinition(c.String("new-pb-dir"), c.StringSlice("new-pb-file"))
+		if err != nil {
+			return err
+		}
+
+		issues := inspector.CheckCompatibility(before, after)
+		if output == "json" {
+			if issues == nil {
+				issues = []inspector.Incompatibility{}
+			}
+			if err := printJSON(issues); err != nil {
+				return err
+			}
+		} else {
+			for _, issue := range issues {
+				fmt.Printf("%s: %s\n", issue.Kind, issue)
+			}
+		}
+
+		if len(issues) > 0 {
+			return fmt.Errorf("%d incompatible changes", len(issues))
+		}
+		return nil
+	},
+}
+
+// readDefinition reads the schema of the .proto files and the files in dir
+func readDefinition(dir string, files []string) (*inspector.Definition, error) {
+	pbfiles, err := walkpb(dir, files)
+	if err != nil {
+		return nil, err
+	}
+	if len(pbfiles) == 0 {
+		return nil, errors.New("compat needs the old and the new schema (--old-pb-file or --old-pb-dir, --new-pb-file or --new-pb-dir)")
+	}
+
+	d := inspector.NewDefinition()
+	for _, file := range pbfiles {
+		if err = d.ReadFile(file); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
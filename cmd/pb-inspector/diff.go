@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"os"
+
+	inspector "github.com/detailyang/pb-inspector-go/protobuf-inspector"
+	"github.com/urfave/cli"
+)
+
+var diffCommand = cli.Command{
+	Name:      "diff",
+	Usage:     "compare two messages field by field, with or without schema",
+	ArgsUsage: "<file> <file> [package] [name]",
+	Action: func(c *cli.Context) error {
+		if c.NArg() < 2 {
+			return cli.ShowCommandHelp(c, c.Command.Name)
+		}
+
+		var raws [2][]byte
+		for i := range raws {
+			raw, err := readRaw(c.Args().Get(i), c.GlobalString("file-type"), c.GlobalString("compression"))
+			if err != nil {
+				return err
+			}
+			raws[i] = raw
+		}
+
+		output := c.GlobalString("output")
+		if output != "text" && output != "json" {
+			return errors.New("diff supports text and json output")
+		}
+
+		in, schema, err := newInspector(c)
+		if err != nil {
+			return err
+		}
+
+		var changes []inspector.Change
+		if schema {
+			pkg, name, err := messageType(c, in, c.Args().Get(2), c.Args().Get(3))
+			if err != nil {
+				return err
+			}
+			changes, err = in.DiffWithSchema(pkg, name, raws[0], raws[1])
+			if err != nil {
+				return err
+			}
+		} else {
+			changes, err = in.DiffWithoutSchema(raws[0], raws[1])
+			if err != nil {
+				return err
+			}
+		}
+
+		if output == "json" {
+			if changes == nil {
+				changes = []inspector.Change{}
+			}
+			return printJSON(changes)
+		}
+		inspector.WriteChanges(os.Stdout, changes)
+		return nil
+	},
+}
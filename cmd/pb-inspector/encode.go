@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/urfave/cli"
+)
+
+var encodeCommand = cli.Command{
+	Name:      "encode",
+	Usage:     "encode a JSON or text format message to protobuf binary by the loaded schema",
+	ArgsUsage: "<file> <package> <name>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "input-type",
+			Value: "json",
+			Usage: "Specify the input type (json or text)",
+		},
+		cli.StringFlag{
+			Name:  "output-type",
+			Value: "binary",
+			Usage: "Specify the output type (binary, hex or base64)",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() == 0 {
+			return cli.ShowCommandHelp(c, c.Command.Name)
+		}
+
+		data, err := readRaw(c.Args().First(), "binary", "none")
+		if err != nil {
+			return err
+		}
+
+		in, schema, err := newInspector(c)
+		if err != nil {
+			return err
+		}
+		if !schema {
+			return errors.New("encode needs a schema (--pb-file or --pb-dir)")
+		}
+
+		pkg, name, err := messageType(c, in, c.Args().Get(1), c.Args().Get(2))
+		if err != nil {
+			return err
+		}
+
+		var raw []byte
+		switch c.String("input-type") {
+		case "json":
+			raw, err = in.EncodeJSON(pkg, name, data)
+		case "text":
+			raw, err = in.EncodeText(pkg, name, data)
+		default:
+			return errors.New("unknow input type")
+		}
+		if err != nil {
+			return err
+		}
+		return writeRaw(raw, c.String("output-type"))
+	},
+}
+
+// writeRaw writes raw to stdout encoded as outputType
+func writeRaw(raw []byte, outputType string) error {
+	switch outputType {
+	case "binary":
+		_, err := os.Stdout.Write(raw)
+		return err
+	case "hex":
+		fmt.Println(hex.EncodeToString(raw))
+		return nil
+	case "base64":
+		fmt.Println(base64.StdEncoding.EncodeToString(raw))
+		return nil
+	}
+	return errors.New("unknow output type")
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os"
+
+	inspector "github.com/detailyang/pb-inspector-go/protobuf-inspector"
+	"github.com/urfave/cli"
+)
+
+var inferCommand = cli.Command{
+	Name:      "infer",
+	Usage:     "infer a .proto schema from sample messages",
+	ArgsUsage: "[file...]",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "package",
+			Value: "",
+			Usage: "Specify the package of the inferred schema",
+		},
+		cli.StringFlag{
+			Name:  "name",
+			Value: "Message",
+			Usage: "Specify the message name of the inferred schema",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() == 0 {
+			return cli.ShowCommandHelp(c, c.Command.Name)
+		}
+
+		var samples [][]byte
+		for _, file := range c.Args() {
+			raw, err := readRaw(file, c.GlobalString("file-type"), c.GlobalString("compression"))
+			if err != nil {
+				return err
+			}
+			samples = append(samples, raw)
+		}
+
+		in := inspector.NewInspector()
+		return in.InferSchema(c.String("package"), c.String("name"), samples, os.Stdout)
+	},
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	inspector "github.com/detailyang/pb-inspector-go/protobuf-inspector"
+)
+
+// readRaw reads the message from file ("-" for stdin) which is encoded as
+// filetype and compressed with compression
+func readRaw(file, filetype, compression string) ([]byte, error) {
+	var r *bufio.Reader
+
+	if file == "-" {
+		r = bufio.NewReader(os.Stdin)
+	} else {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = bufio.NewReader(f)
+	}
+
+	raw, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err = inspector.DecodeInput(raw, filetype)
+	if err != nil {
+		return nil, err
+	}
+
+	b, codec, err := inspector.Decompress(raw, compression)
+	if err != nil {
+		return nil, err
+	}
+	if codec != "" {
+		fmt.Fprintf(os.Stderr, "%s: decompressed %s %d -> %d bytes\n", file, codec, len(raw), len(b))
+	}
+	return b, nil
+}
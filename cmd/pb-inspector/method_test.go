@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// runApp runs the command line with args and returns what it printed
+func runApp(t *testing.T, args ...string) (string, error) {
+	r, w, err := os.Pipe()
+	require.Nil(t, err)
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	err = newApp().Run(append([]string{"pb-inspector"}, args...))
+	w.Close()
+	out, rerr := ioutil.ReadAll(r)
+	require.Nil(t, rerr)
+	return string(out), err
+}
+
+func TestMethod(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pb-inspector")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	schema := filepath.Join(dir, "echo.proto")
+	require.Nil(t, ioutil.WriteFile(schema, []byte(`
+syntax = "proto3";
+
+package echo.v1;
+
+message EchoRequest {
+  string message = 1;
+}
+
+message EchoResponse {
+  string reply = 1;
+}
+
+service Echo {
+  rpc Echo (EchoRequest) returns (EchoResponse);
+}
+`), 0644))
+	input := filepath.Join(dir, "message.hex")
+	require.Nil(t, ioutil.WriteFile(input, []byte("0a03626f62"), 0644))
+
+	flags := []string{"--pb-file", schema, "--file-type", "hex", "--output", "json", "--method", "echo.v1.Echo/Echo"}
+	tests := []struct {
+		flags  []string
+		output string
+	}{
+		{nil, "{\n  \"message\": \"bob\"\n}\n"},
+		{[]string{"--request"}, "{\n  \"message\": \"bob\"\n}\n"},
+		{[]string{"--response"}, "{\n  \"reply\": \"bob\"\n}\n"},
+	}
+	for _, test := range tests {
+		args := append(append(append([]string{}, flags...), test.flags...), input)
+		out, err := runApp(t, args...)
+		require.Nil(t, err, test.flags)
+		require.Equal(t, test.output, out, test.flags)
+	}
+
+	_, err = runApp(t, append(flags, "--request", "--response", input)...)
+	require.Equal(t, "specify either --request or --response", err.Error())
+
+	_, err = runApp(t, "--pb-file", schema, "--file-type", "hex", "--method", "echo.v1.Echo/Missing", input)
+	require.Equal(t, "inspector: unknown method echo.v1.Echo/Missing", err.Error())
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/urfave/cli"
+)
+
+var patchCommand = cli.Command{
+	Name:      "patch",
+	Usage:     "change fields of a protobuf binary message in place by the loaded schema",
+	ArgsUsage: "<file> <package> <name>",
+	Flags: []cli.Flag{
+		cli.StringSliceFlag{
+			Name:  "edit",
+			Usage: "Set a field like test2.name=\"bob\", the value is JSON, or delete one like \"delete items[1]\" (repeatable, applied in order)",
+		},
+		cli.StringFlag{
+			Name:  "output-type",
+			Value: "binary",
+			Usage: "Specify the output type (binary, hex or base64)",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() == 0 {
+			return cli.ShowCommandHelp(c, c.Command.Name)
+		}
+
+		raw, err := readRaw(c.Args().First(), c.GlobalString("file-type"), c.GlobalString("compression"))
+		if err != nil {
+			return err
+		}
+
+		in, schema, err := newInspector(c)
+		if err != nil {
+			return err
+		}
+		if !schema {
+			return errors.New("patch needs a schema (--pb-file or --pb-dir)")
+		}
+
+		pkg, name, err := messageType(c, in, c.Args().Get(1), c.Args().Get(2))
+		if err != nil {
+			return err
+		}
+
+		edits := c.StringSlice("edit")
+		if len(edits) == 0 {
+			return errors.New("patch needs --edit")
+		}
+
+		raw, err = in.Patch(pkg, name, raw, edits)
+		if err != nil {
+			return err
+		}
+		return writeRaw(raw, c.String("output-type"))
+	},
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatchEditOrder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pb-inspector")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "message.hex")
+	require.Nil(t, ioutil.WriteFile(input, []byte("0801"), 0644))
+
+	flags := []string{"--pb-file", "../../proto/test/v1/test.proto", "--file-type", "hex", "patch", "--output-type", "hex"}
+	tests := []struct {
+		edits  []string
+		output string
+	}{
+		{[]string{"--edit", "int32=7", "--edit", "delete int32"}, "\n"},
+		{[]string{"--edit", "delete int32", "--edit", "int32=7"}, "0807\n"},
+	}
+	for _, test := range tests {
+		args := append(append(append([]string{}, flags...), test.edits...), input, "test.v1", "Test")
+		out, err := runApp(t, args...)
+		require.Nil(t, err, test.edits)
+		require.Equal(t, test.output, out, test.edits)
+	}
+}
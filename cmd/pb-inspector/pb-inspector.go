@@ -3,12 +3,11 @@ package main
 // echo 08ffff01100840f7d438 | xxd -r -p | bin/pb-inspector -
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
@@ -20,6 +19,14 @@ import (
 )
 
 func main() {
+	err := newApp().Run(os.Args)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// newApp returns the command line application
+func newApp() *cli.App {
 	app := cli.NewApp()
 	app.Name = "pb-inspector"
 	app.Description = "pb-protobuf inspects protobuf binary file"
@@ -32,7 +39,12 @@ func main() {
 		cli.StringFlag{
 			Name:  "file-type",
 			Value: "binary",
-			Usage: "Specify the file type (binary or hex)",
+			Usage: "Specify the file type (binary, hex, base64, base64url, escaped or auto)",
+		},
+		cli.StringFlag{
+			Name:  "compression",
+			Value: "auto",
+			Usage: "Specify the compression (none, gzip, zstd, snappy or auto)",
 		},
 		cli.StringSliceFlag{
 			Name:  "pb-file",
@@ -44,6 +56,51 @@ func main() {
 			Value: "",
 			Usage: "Load *.pb *.proto from directory",
 		},
+		cli.StringFlag{
+			Name:  "output",
+			Value: "text",
+			Usage: "Specify the output format (text, html, json or scope)",
+		},
+		cli.StringFlag{
+			Name:  "stream",
+			Value: "",
+			Usage: "Split the input into a stream of messages (delimited|grpc)",
+		},
+		cli.StringFlag{
+			Name:  "method",
+			Value: "",
+			Usage: "Pick the message type by method (pkg.Service/Method) instead of <package> <name>",
+		},
+		cli.BoolFlag{
+			Name:  "request",
+			Usage: "Decode the request message of --method (default)",
+		},
+		cli.BoolFlag{
+			Name:  "response",
+			Usage: "Decode the response message of --method",
+		},
+		cli.StringSliceFlag{
+			Name:  "select",
+			Usage: "Output only the values at a path like test2.name, items[*].id or attrs[\"k\"] (repeatable)",
+		},
+		cli.BoolFlag{
+			Name:  "recover",
+			Usage: "Skip over damaged bytes instead of stopping when decoding without schema",
+		},
+		cli.BoolFlag{
+			Name:  "decode-bytes",
+			Usage: "Decode bytes fields which look like messages without schema",
+		},
+	}
+	app.Commands = []cli.Command{
+		inferCommand,
+		pcapCommand,
+		encodeCommand,
+		assembleCommand,
+		patchCommand,
+		diffCommand,
+		compatCommand,
+		validateCommand,
 	}
 	app.Action = func(c *cli.Context) error {
 		if c.NArg() == 0 {
@@ -51,11 +108,7 @@ func main() {
 		}
 		return run(c)
 	}
-
-	err := app.Run(os.Args)
-	if err != nil {
-		log.Fatal(err)
-	}
+	return app
 }
 
 func run(c *cli.Context) error {
@@ -63,76 +116,239 @@ func run(c *cli.Context) error {
 	pkg := c.Args().Get(1)
 	name := c.Args().Get(2)
 
-	var r *bufio.Reader
+	raw, err := readRaw(file, c.String("file-type"), c.String("compression"))
+	if err != nil {
+		return err
+	}
+
+	output := c.String("output")
+	selects := c.StringSlice("select")
+	if err = checkOutput(output, selects); err != nil {
+		return err
+	}
 
-	if file == "-" {
-		r = bufio.NewReader(os.Stdin)
-	} else {
-		f, err := os.Open(file)
-		if err != nil {
-			return err
-		}
-		r = bufio.NewReader(f)
+	in, schema, err := newInspector(c)
+	if err != nil {
+		return err
 	}
 
-	raw, err := ioutil.ReadAll(r)
+	pkg, name, err = messageType(c, in, pkg, name)
 	if err != nil {
 		return err
 	}
 
-	filetype := c.String("file-type")
-	switch filetype {
-	case "binary":
-		break
-	case "hex":
-		var err error
-		raw, err = hex.DecodeString(strings.TrimSpace(string(raw)))
-		if err != nil {
-			return err
+	switch c.String("stream") {
+	case "":
+		return inspect(in, raw, pkg, name, output, schema, selects)
+	case "delimited":
+		messages, err := inspector.SplitDelimited(raw)
+		if perr := inspectStream(in, messages, pkg, name, output, schema, selects); perr != nil {
+			return perr
 		}
+		return err
+	case "grpc":
+		messages, err := inspector.SplitGRPC(raw)
+		if perr := inspectStream(in, messages, pkg, name, output, schema, selects); perr != nil {
+			return perr
+		}
+		return err
 	default:
-		return errors.New("unknow file type")
+		return errors.New("unknow stream type")
 	}
+}
 
-	pbfiles := c.StringSlice("pb-file")
-	pbdir := c.String("pb-dir")
-	pbfiles, err = walkpb(pbdir, pbfiles)
+// newInspector returns an Inspector configured by the global flags and
+// whether a schema was loaded into it
+func newInspector(c *cli.Context) (*inspector.Inspector, bool, error) {
+	pbfiles, err := walkpb(c.GlobalString("pb-dir"), c.GlobalStringSlice("pb-file"))
 	if err != nil {
-		return err
+		return nil, false, err
 	}
 
-	w := bytes.NewBuffer(nil)
 	in := inspector.NewInspector()
+	in.SetDecodeBytes(c.GlobalBool("decode-bytes"))
+	in.SetRecover(c.GlobalBool("recover"))
 
-	if len(pbfiles) == 0 {
-		if err := in.InspectWithoutSchema(false, raw, w); err != nil {
-			return err
+	for _, file := range pbfiles {
+		if err = in.ReadSchemaFromFile(file); err != nil {
+			return nil, false, err
 		}
+	}
+	return in, len(pbfiles) > 0, nil
+}
 
-		fmt.Println(hex.EncodeToString(raw))
-		fmt.Println("=>")
-		fmt.Println(w.String())
+// messageType returns the message type picked by the global --method flag,
+// or pkg.name without it
+func messageType(c *cli.Context, in *inspector.Inspector, pkg, name string) (string, string, error) {
+	method := c.GlobalString("method")
+	if method == "" {
+		return pkg, name, nil
+	}
+	if c.GlobalBool("request") && c.GlobalBool("response") {
+		return "", "", errors.New("specify either --request or --response")
+	}
+	return in.MethodMessage(method, c.GlobalBool("response"))
+}
 
-	} else {
-		for _, file := range pbfiles {
-			if err = in.ReadSchemaFromFile(file); err != nil {
+func checkOutput(output string, selects []string) error {
+	switch output {
+	case "text", "json":
+		return nil
+	case "html", "scope":
+		if len(selects) > 0 {
+			return errors.New("--select supports text and json output")
+		}
+		return nil
+	}
+	return errors.New("unknow output format")
+}
+
+// inspectStream prints every message of a stream after its index and offset.
+// The json output is a line of JSON for each message.
+func inspectStream(in *inspector.Inspector, messages []inspector.StreamMessage, pkg, name, output string, schema bool, selects []string) error {
+	if output == "html" {
+		return errors.New("html output does not support streams")
+	}
+
+	for _, m := range messages {
+		if output == "json" {
+			v, err := decodeJSON(in, m.Raw, pkg, name, schema, selects)
+			if err != nil {
+				return err
+			}
+			if err := printJSONLine(streamMessage{m.Index, m.Offset, len(m.Raw), m.Compressed, v}); err != nil {
 				return err
 			}
+			continue
 		}
 
-		m, err := in.ToMapWithSchema(pkg, name, raw)
+		if m.Compressed {
+			fmt.Printf("#%d offset=%d length=%d compressed\n", m.Index, m.Offset, len(m.Raw))
+		} else {
+			fmt.Printf("#%d offset=%d length=%d\n", m.Index, m.Offset, len(m.Raw))
+		}
+		if err := inspect(in, m.Raw, pkg, name, output, schema, selects); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamMessage is the json output of a message of a stream
+type streamMessage struct {
+	Index      int         `json:"index"`
+	Offset     int         `json:"offset"`
+	Length     int         `json:"length"`
+	Compressed bool        `json:"compressed,omitempty"`
+	Message    interface{} `json:"message"`
+}
+
+// inspect prints the message raw in output format, decoded by pkg.name if
+// schema is loaded
+func inspect(in *inspector.Inspector, raw []byte, pkg, name, output string, schema bool, selects []string) error {
+	if output == "json" {
+		v, err := decodeJSON(in, raw, pkg, name, schema, selects)
 		if err != nil {
 			return err
 		}
+		return printJSON(v)
+	}
+
+	if len(selects) > 0 {
+		return inspectSelect(in, raw, pkg, name, schema, selects)
+	}
+
+	if output == "scope" {
+		in.SetDisassemble(true)
+		return in.InspectWithoutSchema(false, raw, os.Stdout)
+	}
+
+	if !schema {
+		if output == "html" {
+			return in.ReportWithoutSchema(raw, os.Stdout)
+		}
+
+		w := bytes.NewBuffer(nil)
+		if err := in.InspectWithoutSchema(false, raw, w); err != nil {
+			return err
+		}
 
 		fmt.Println(hex.EncodeToString(raw))
-		fmt.Printf("=> (pkg=%s name=%s)\n", pkg, name)
-		pp.Println(m)
+		fmt.Println("=>")
+		fmt.Println(w.String())
+		return nil
+	}
+
+	if output == "html" {
+		return in.ReportWithSchema(pkg, name, raw, os.Stdout)
+	}
+
+	m, err := in.ToMapWithSchema(pkg, name, raw)
+	if err != nil {
+		return err
 	}
 
+	fmt.Println(hex.EncodeToString(raw))
+	fmt.Printf("=> (pkg=%s name=%s)\n", pkg, name)
+	pp.Println(m)
 	return nil
 }
 
+// inspectSelect prints the values of the message at the paths selects
+func inspectSelect(in *inspector.Inspector, raw []byte, pkg, name string, schema bool, selects []string) error {
+	selections, err := selectAll(in, raw, pkg, name, schema, selects)
+	if err != nil {
+		return err
+	}
+	inspector.WriteSelections(os.Stdout, selections)
+	return nil
+}
+
+// selectAll returns the values of raw at every path of selects, decoding
+// raw once
+func selectAll(in *inspector.Inspector, raw []byte, pkg, name string, schema bool, selects []string) ([]inspector.Selection, error) {
+	var (
+		selections []inspector.Selection
+		err        error
+	)
+	if schema {
+		selections, err = in.SelectWithSchema(pkg, name, raw, selects...)
+	} else {
+		selections, err = in.SelectWithoutSchema(raw, selects...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if selections == nil {
+		// printed as [] rather than null
+		selections = []inspector.Selection{}
+	}
+	return selections, nil
+}
+
+// decodeJSON returns what the json output prints for the message raw: the
+// selections if any, or the message decoded by pkg.name if schema is loaded
+func decodeJSON(in *inspector.Inspector, raw []byte, pkg, name string, schema bool, selects []string) (interface{}, error) {
+	if len(selects) > 0 {
+		return selectAll(in, raw, pkg, name, schema, selects)
+	}
+	if !schema {
+		return in.DecodeWithoutSchema(raw)
+	}
+	return in.ToMapWithSchema(pkg, name, raw)
+}
+
+func printJSON(v interface{}) error {
+	e := json.NewEncoder(os.Stdout)
+	e.SetIndent("", "  ")
+	return e.Encode(v)
+}
+
+// printJSONLine prints v as a single line of JSON
+func printJSONLine(v interface{}) error {
+	return json.NewEncoder(os.Stdout).Encode(v)
+}
+
 func walkpb(dir string, files []string) ([]string, error) {
 	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
 		if strings.HasSuffix(path, ".pb") || strings.HasSuffix(path, ".proto") {
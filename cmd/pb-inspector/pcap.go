@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	inspector "github.com/detailyang/pb-inspector-go/protobuf-inspector"
+	"github.com/urfave/cli"
+)
+
+var pcapCommand = cli.Command{
+	Name:      "pcap",
+	Usage:     "decode the gRPC messages of a pcap or pcapng capture by the methods of their :path",
+	ArgsUsage: "<file>",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "request-type",
+			Value: "",
+			Usage: "Specify the full name of the request message, e.g. test.v1.Test",
+		},
+		cli.StringFlag{
+			Name:  "response-type",
+			Value: "",
+			Usage: "Specify the full name of the response message, e.g. test.v1.Test2",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		if c.NArg() == 0 {
+			return cli.ShowCommandHelp(c, c.Command.Name)
+		}
+
+		output := c.GlobalString("output")
+		selects := c.GlobalStringSlice("select")
+		if err := checkOutput(output, selects); err != nil {
+			return err
+		}
+		if output == "html" {
+			return errors.New("html output does not support captures")
+		}
+
+		in, schema, err := newInspector(c)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.Open(c.Args().First())
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		messages, err := inspector.ReadCapture(f)
+		for _, m := range messages {
+			typ, kind := c.String("request-type"), "request"
+			if m.Response {
+				typ, kind = c.String("response-type"), "response"
+			}
+			if typ == "" && schema {
+				// Pick the type by the :path, or fall back to decoding without schema
+				if pkg, name, perr := in.MethodMessage(m.Path, m.Response); perr == nil {
+					typ = pkg + "." + name
+				}
+			}
+			pkg, name := splitFullName(typ)
+
+			if output == "json" {
+				v, perr := decodeJSON(in, m.Raw, pkg, name, typ != "", selects)
+				if perr != nil {
+					return perr
+				}
+				if perr := printJSONLine(capturedMessage{m.Index, m.Connection, m.StreamID, m.Path, kind, len(m.Raw), m.Compressed, v}); perr != nil {
+					return perr
+				}
+				continue
+			}
+
+			fmt.Printf("#%d %s stream=%d %s %s length=%d", m.Index, m.Connection, m.StreamID, m.Path, kind, len(m.Raw))
+			if m.Compressed {
+				fmt.Print(" compressed")
+			}
+			fmt.Println()
+			if perr := inspect(in, m.Raw, pkg, name, output, typ != "", selects); perr != nil {
+				return perr
+			}
+		}
+		return err
+	},
+}
+
+// capturedMessage is the json output of a message of a capture
+type capturedMessage struct {
+	Index      int         `json:"index"`
+	Connection string      `json:"connection"`
+	StreamID   uint32      `json:"stream"`
+	Path       string      `json:"path"`
+	Kind       string      `json:"kind"`
+	Length     int         `json:"length"`
+	Compressed bool        `json:"compressed,omitempty"`
+	Message    interface{} `json:"message"`
+}
+
+// splitFullName splits a full message name like test.v1.Test into its
+// package and name
+func splitFullName(full string) (pkg, name string) {
+	i := strings.LastIndex(full, ".")
+	if i < 0 {
+		return "", full
+	}
+	return full[:i], full[i+1:]
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	inspector "github.com/detailyang/pb-inspector-go/protobuf-inspector"
+	"github.com/urfave/cli"
+)
+
+var validateCommand = cli.Command{
+	Name:      "validate",
+	Usage:     "check a message strictly against the loaded schema and report every problem",
+	ArgsUsage: "<file> <package> <name>",
+	Action: func(c *cli.Context) error {
+		if c.NArg() == 0 {
+			return cli.ShowCommandHelp(c, c.Command.Name)
+		}
+
+		raw, err := readRaw(c.Args().First(), c.GlobalString("file-type"), c.GlobalString("compression"))
+		if err != nil {
+			return err
+		}
+
+		output := c.GlobalString("output")
+		if output != "text" && output != "json" {
+			return errors.New("validate supports text and json output")
+		}
+
+		in, schema, err := newInspector(c)
+		if err != nil {
+			return err
+		}
+		if !schema {
+			return errors.New("validate needs a schema (--pb-file or --pb-dir)")
+		}
+
+		pkg, name, err := messageType(c, in, c.Args().Get(1), c.Args().Get(2))
+		if err != nil {
+			return err
+		}
+
+		issues, err := in.Validate(pkg, name, raw)
+		if err != nil {
+			return err
+		}
+
+		if output == "json" {
+			if issues == nil {
+				issues = []inspector.Issue{}
+			}
+			if err := printJSON(issues); err != nil {
+				return err
+			}
+		} else {
+			inspector.WriteIssues(os.Stdout, issues)
+		}
+
+		if len(issues) > 0 {
+			return fmt.Errorf("%d issues found in %s.%s", len(issues), pkg, name)
+		}
+		return nil
+	},
+}
@@ -6,8 +6,13 @@ require (
 	github.com/emicklei/proto v1.6.13
 	github.com/gogo/protobuf v1.2.1
 	github.com/golang/protobuf v1.3.2
+	github.com/golang/snappy v0.0.1
+	github.com/google/gopacket v1.1.17
+	github.com/k0kubun/colorstring v0.0.0-20150214042306-9440f1994b88 // indirect
 	github.com/k0kubun/pp v3.0.1+incompatible
+	github.com/klauspost/compress v1.9.8
 	github.com/mattn/go-colorable v0.1.2 // indirect
 	github.com/stretchr/testify v1.4.0
 	github.com/urfave/cli v1.21.0
+	golang.org/x/net v0.0.0-20190620200207-3b0461eec859
 )
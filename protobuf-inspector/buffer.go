@@ -4,8 +4,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-
-	"github.com/gogo/protobuf/proto"
 )
 
 // errOverflow is returned when an integer is too large to be represented.
@@ -24,6 +22,11 @@ type Buffer struct {
 	buf     []byte // encode/decode byte stream
 	index   int    // read point
 	decoder *Decoder
+	recover bool // resynchronize after malformed fields when decoding without schema
+
+	diagnostics []Diagnostic // problems found when decoding without schema
+	failed      bool         // whether the failure has been diagnosed
+	depth       int          // number of open groups and messages when decoding without schema
 }
 
 // NewBuffer allocates a new Buffer and initializes its internal data to
@@ -282,117 +285,7 @@ func (p *Buffer) DecodeStringBytes() (s string, err error) {
 
 // InspectWithoutSchema inspects raw protobuf binary data and write to w
 func (p *Buffer) InspectWithoutSchema(verbose bool, raw []byte, w io.Writer) error {
-	var (
-		err    error
-		u      = uint64(0)
-		obuf   = p.buf
-		sindex = p.index
-		depth  = 0
-		op     uint64
-	)
-
-	p.buf = raw
-	p.index = 0
-
-out:
-	for {
-		for i := 0; i < depth; i++ {
-			fmt.Fprint(w, "  ")
-		}
-
-		index := p.index
-		if index == len(p.buf) {
-			break
-		}
-
-		// Each key in the streamed message is a varint with the value (field_number << 3) | wire_type –
-		// in other words, the last three bits of the number store the wire type.
-		op, err = p.DecodeVarint()
-		if err != nil {
-			err = fmt.Errorf("insepctor: [%3d] fetching op err %v", index, err)
-			break out
-		}
-		tag := op >> 3
-		wire := op & 7
-
-		switch wire {
-		default:
-			err = fmt.Errorf("Buffer: [%3d] t=%3d unknown wire=%d", index, tag, wire)
-			break out
-
-		case proto.WireBytes:
-			var r []byte
-
-			r, err = p.DecodeRawBytes(true)
-			if err != nil {
-				err = fmt.Errorf("insepctor: [%3d] t=%3d bytes err %v", index, tag, err)
-				break out
-			}
-
-			fmt.Fprintf(w, "%3d: t=%3d bytes [%d]", index, tag, len(r))
-
-			if verbose {
-				for i := 0; i < len(r); i++ {
-					fmt.Fprintf(w, " %.2x", r[i])
-				}
-
-			} else {
-				if len(r) <= 6 {
-					for i := 0; i < len(r); i++ {
-						fmt.Fprintf(w, " %.2x", r[i])
-					}
-				} else {
-					for i := 0; i < 3; i++ {
-						fmt.Fprintf(w, " %.2x", r[i])
-					}
-					fmt.Fprintf(w, " ..")
-					for i := len(r) - 3; i < len(r); i++ {
-						fmt.Fprintf(w, " %.2x", r[i])
-					}
-				}
-			}
-			fmt.Fprintf(w, "\n")
-
-		case proto.WireFixed32:
-			u, err = p.DecodeFixed32()
-			if err != nil {
-				err = fmt.Errorf("insepctor: [%3d] t=%3d fix32 err %v", index, tag, err)
-				break out
-			}
-			fmt.Fprintf(w, "%3d: t=%3d fix32 %d\n", index, tag, u)
-
-		case proto.WireFixed64:
-			u, err = p.DecodeFixed64()
-			if err != nil {
-				err = fmt.Errorf("insepctor: [%3d] t=%3d fix64 err %v", index, tag, err)
-				break out
-			}
-			fmt.Fprintf(w, "%3d: t=%3d fix64 %d\n", index, tag, u)
-
-		case proto.WireVarint:
-			u, err = p.DecodeVarint()
-			if err != nil {
-				err = fmt.Errorf("%3d: t=%3d varint err %v", index, tag, err)
-				break out
-			}
-			fmt.Fprintf(w, "%3d: t=%3d varint %d\n", index, tag, u)
-
-		case proto.WireStartGroup:
-			fmt.Fprintf(w, "%3d: t=%3d start\n", index, tag)
-			depth++
-
-		case proto.WireEndGroup:
-			depth--
-			fmt.Fprintf(w, "%3d: t=%3d end\n", index, tag)
-		}
-	}
-
-	if depth != 0 {
-		fmt.Fprintf(w, "%3d: start-end not balanced %d\n", p.index, depth)
-	}
-
-	p.buf = obuf
-	p.index = sindex
-
+	m, err := DecodeWithoutSchema(raw)
+	m.WriteText(w, verbose)
 	return err
 }
@@ -0,0 +1,214 @@
+package inspector
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/google/gopacket/tcpassembly"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// pcapngMagic is the block type of the section header which starts a pcapng file
+const pcapngMagic = 0x0a0d0d0a
+
+// CapturedMessage is a gRPC message found in a packet capture. Connection is
+// the client and server addresses of its TCP connection and Path the :path
+// of its HTTP/2 stream.
+type CapturedMessage struct {
+	Index      int
+	Connection string
+	StreamID   uint32
+	Path       string
+	Response   bool
+	Raw        []byte
+	Compressed bool
+}
+
+type captureSource interface {
+	gopacket.PacketDataSource
+	LinkType() layers.LinkType
+}
+
+// tcpStream collects the reassembled bytes of one direction of a TCP connection
+type tcpStream struct {
+	net       gopacket.Flow
+	transport gopacket.Flow
+	buf       bytes.Buffer
+}
+
+func (s *tcpStream) Reassembled(rs []tcpassembly.Reassembly) {
+	for _, r := range rs {
+		s.buf.Write(r.Bytes)
+	}
+}
+
+func (s *tcpStream) ReassemblyComplete() {}
+
+type tcpStreamFactory struct {
+	streams []*tcpStream
+}
+
+func (f *tcpStreamFactory) New(net, transport gopacket.Flow) tcpassembly.Stream {
+	s := &tcpStream{net: net, transport: transport}
+	f.streams = append(f.streams, s)
+	return s
+}
+
+// http2Stream is what one direction of an HTTP/2 connection sent on a stream
+type http2Stream struct {
+	path string
+	data []byte
+}
+
+// ReadCapture reads a pcap or pcapng capture, reassembles its TCP
+// connections and returns the gRPC messages of the HTTP/2 connections, in
+// the order their streams were opened. Connections whose start was not
+// captured are skipped since their HPACK state is unknown. On error it
+// returns the messages found so far as well.
+func ReadCapture(r io.Reader) ([]CapturedMessage, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("inspector: read capture %v", err)
+	}
+
+	var src captureSource
+	if binary.LittleEndian.Uint32(magic) == pcapngMagic {
+		src, err = pcapgo.NewNgReader(br, pcapgo.DefaultNgReaderOptions)
+	} else {
+		src, err = pcapgo.NewReader(br)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("inspector: read capture %v", err)
+	}
+
+	var (
+		messages []CapturedMessage
+		first    error
+	)
+
+	factory := &tcpStreamFactory{}
+	assembler := tcpassembly.NewAssembler(tcpassembly.NewStreamPool(factory))
+	packets := gopacket.NewPacketSource(src, src.LinkType())
+	for {
+		p, err := packets.NextPacket()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// the packets so far may hold messages of a truncated capture
+			first = fmt.Errorf("inspector: read capture %v", err)
+			break
+		}
+
+		if p.NetworkLayer() == nil {
+			continue
+		}
+		tcp, ok := p.TransportLayer().(*layers.TCP)
+		if !ok {
+			continue
+		}
+		assembler.AssembleWithTimestamp(p.NetworkLayer().NetworkFlow(), tcp, p.Metadata().Timestamp)
+	}
+	assembler.FlushAll()
+
+	directions := map[[2]gopacket.Flow]*tcpStream{}
+	for _, s := range factory.streams {
+		directions[[2]gopacket.Flow{s.net, s.transport}] = s
+	}
+
+	for _, client := range factory.streams {
+		if !bytes.HasPrefix(client.buf.Bytes(), []byte(http2.ClientPreface)) {
+			continue
+		}
+		conn := fmt.Sprintf("%s:%s -> %s:%s", client.net.Src(), client.transport.Src(),
+			client.net.Dst(), client.transport.Dst())
+
+		requests, order, err := readHTTP2(client.buf.Bytes()[len(http2.ClientPreface):])
+		if err != nil && first == nil {
+			first = fmt.Errorf("inspector: %s requests %v", conn, err)
+		}
+		responses := map[uint32]*http2Stream{}
+		if server, ok := directions[[2]gopacket.Flow{client.net.Reverse(), client.transport.Reverse()}]; ok {
+			responses, _, err = readHTTP2(server.buf.Bytes())
+			if err != nil && first == nil {
+				first = fmt.Errorf("inspector: %s responses %v", conn, err)
+			}
+		}
+
+		for _, id := range order {
+			path := requests[id].path
+			for i, s := range []*http2Stream{requests[id], responses[id]} {
+				if s == nil {
+					continue
+				}
+				frames, err := SplitGRPC(s.data)
+				if err != nil && first == nil {
+					first = fmt.Errorf("inspector: %s stream %d %v", conn, id, err)
+				}
+				for _, f := range frames {
+					messages = append(messages, CapturedMessage{
+						Index:      len(messages),
+						Connection: conn,
+						StreamID:   id,
+						Path:       path,
+						Response:   i == 1,
+						Raw:        f.Raw,
+						Compressed: f.Compressed,
+					})
+				}
+			}
+		}
+	}
+
+	return messages, first
+}
+
+// readHTTP2 reads the HTTP/2 frames sent by one side of a connection and
+// returns its streams by id along with the ids in the order they were opened
+func readHTTP2(raw []byte) (map[uint32]*http2Stream, []uint32, error) {
+	var (
+		streams = map[uint32]*http2Stream{}
+		order   []uint32
+	)
+	stream := func(id uint32) *http2Stream {
+		s, ok := streams[id]
+		if !ok {
+			s = &http2Stream{}
+			streams[id] = s
+			order = append(order, id)
+		}
+		return s
+	}
+
+	framer := http2.NewFramer(nil, bytes.NewReader(raw))
+	framer.SetMaxReadFrameSize(1<<24 - 1)
+	framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	for {
+		frame, err := framer.ReadFrame()
+		if err == io.EOF {
+			return streams, order, nil
+		}
+		if err != nil {
+			return streams, order, err
+		}
+
+		switch f := frame.(type) {
+		case *http2.MetaHeadersFrame:
+			s := stream(f.StreamID)
+			if path := f.PseudoValue("path"); path != "" {
+				s.path = path
+			}
+		case *http2.DataFrame:
+			s := stream(f.StreamID)
+			s.data = append(s.data, f.Data()...)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package inspector
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// captureWriter writes the packets of one TCP connection to a pcap file
+type captureWriter struct {
+	t   *testing.T
+	w   *pcapgo.Writer
+	seq [2]uint32
+}
+
+func (c *captureWriter) send(server bool, syn bool, payload []byte) {
+	ips := [2]net.IP{net.IP{10, 0, 0, 1}, net.IP{10, 0, 0, 2}}
+	ports := [2]layers.TCPPort{40000, 50051}
+	from, to := 0, 1
+	if server {
+		from, to = 1, 0
+	}
+
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 1},
+		DstMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 2},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: ips[from], DstIP: ips[to]}
+	tcp := &layers.TCP{SrcPort: ports[from], DstPort: ports[to], Seq: c.seq[from], SYN: syn, ACK: !syn, Window: 65535}
+	require.Nil(c.t, tcp.SetNetworkLayerForChecksum(ip))
+
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	require.Nil(c.t, gopacket.SerializeLayers(buf, opts, eth, ip, tcp, gopacket.Payload(payload)))
+	require.Nil(c.t, c.w.WritePacket(gopacket.CaptureInfo{
+		Timestamp:     time.Unix(1500000000, 0),
+		CaptureLength: len(buf.Bytes()),
+		Length:        len(buf.Bytes()),
+	}, buf.Bytes()))
+
+	c.seq[from] += uint32(len(payload))
+	if syn {
+		c.seq[from]++
+	}
+}
+
+func TestReadCapture(t *testing.T) {
+	pcap := bytes.NewBuffer(nil)
+	w := pcapgo.NewWriter(pcap)
+	require.Nil(t, w.WriteFileHeader(65535, layers.LinkTypeEthernet))
+	c := &captureWriter{t: t, w: w, seq: [2]uint32{100, 200}}
+
+	frames := func(client bool, path string, message []byte) []byte {
+		b := bytes.NewBuffer(nil)
+		if client {
+			b.WriteString(http2.ClientPreface)
+		}
+		fr := http2.NewFramer(b, nil)
+		require.Nil(t, fr.WriteSettings())
+
+		hb := bytes.NewBuffer(nil)
+		enc := hpack.NewEncoder(hb)
+		if client {
+			require.Nil(t, enc.WriteField(hpack.HeaderField{Name: ":method", Value: "POST"}))
+			require.Nil(t, enc.WriteField(hpack.HeaderField{Name: ":path", Value: path}))
+		} else {
+			require.Nil(t, enc.WriteField(hpack.HeaderField{Name: ":status", Value: "200"}))
+		}
+		require.Nil(t, enc.WriteField(hpack.HeaderField{Name: "content-type", Value: "application/grpc"}))
+		require.Nil(t, fr.WriteHeaders(http2.HeadersFrameParam{StreamID: 1, BlockFragment: hb.Bytes(), EndHeaders: true}))
+
+		data := append([]byte{0, 0, 0, 0, byte(len(message))}, message...)
+		require.Nil(t, fr.WriteData(1, false, data[:3]))
+		require.Nil(t, fr.WriteData(1, client, data[3:]))
+		return b.Bytes()
+	}
+
+	c.send(false, true, nil)
+	c.send(true, true, nil)
+	request := frames(true, "/test.v1.Service/Get", []byte{0x08, 0x01})
+	c.send(false, false, request[:10])
+	c.send(false, false, request[10:])
+	c.send(true, false, frames(false, "", []byte{0x0a, 0x03, 0x62, 0x6f, 0x62}))
+
+	truncated := pcap.Bytes()[:pcap.Len()-5]
+	messages, err := ReadCapture(bytes.NewReader(pcap.Bytes()))
+	require.Nil(t, err)
+	require.Equal(t, []CapturedMessage{
+		{
+			Index:      0,
+			Connection: "10.0.0.1:40000 -> 10.0.0.2:50051",
+			StreamID:   1,
+			Path:       "/test.v1.Service/Get",
+			Raw:        []byte{0x08, 0x01},
+		},
+		{
+			Index:      1,
+			Connection: "10.0.0.1:40000 -> 10.0.0.2:50051",
+			StreamID:   1,
+			Path:       "/test.v1.Service/Get",
+			Response:   true,
+			Raw:        []byte{0x0a, 0x03, 0x62, 0x6f, 0x62},
+		},
+	}, messages)
+
+	// a capture cut short in the last packet keeps the messages before it
+	partial, err := ReadCapture(bytes.NewReader(truncated))
+	require.NotNil(t, err)
+	require.Equal(t, messages[:1], partial)
+
+	_, err = ReadCapture(bytes.NewReader([]byte{1, 2}))
+	require.NotNil(t, err)
+}
@@ -0,0 +1,223 @@
+package inspector
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	pp "github.com/emicklei/proto"
+	pb "github.com/golang/protobuf/proto"
+)
+
+// The kinds of an Incompatibility
+const (
+	IncompatibleNumberReused     = "number reused"
+	IncompatibleWireType         = "wire type changed"
+	IncompatibleFieldRemoved     = "field removed"
+	IncompatibleEnumValueRemoved = "enum value removed"
+	IncompatibleRequiredAdded    = "required field added"
+)
+
+// Incompatibility is a change between two versions of a schema which breaks
+// decoding messages of one version with the other. Element is the full name
+// of the field or enum value, like test.v1.Test.int32
+type Incompatibility struct {
+	Kind    string `json:"kind"`
+	Element string `json:"element"`
+	Message string `json:"message"`
+}
+
+func (i Incompatibility) String() string {
+	return fmt.Sprintf("%s: %s", i.Element, i.Message)
+}
+
+// CheckCompatibility compares the messages and enums declared in both the
+// definition before and after a change of the schema and returns the
+// wire-breaking changes, sorted by element. Type changes are compared by
+// wire type only, so those which keep it but change the encoding of the
+// values, like int32 to sint32 or string to bytes, pass.
+func CheckCompatibility(before, after *Definition) []Incompatibility {
+	var issues []Incompatibility
+
+	for key, om := range before.messages {
+		if nm, ok := after.messages[key]; ok {
+			issues = append(issues, checkMessage(before, after, packageOfKey(before, key), key, om, nm)...)
+		}
+	}
+	for key, oe := range before.enums {
+		if ne, ok := after.enums[key]; ok {
+			issues = append(issues, checkEnum(key, oe, ne)...)
+		}
+	}
+
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i].Element != issues[j].Element {
+			return issues[i].Element < issues[j].Element
+		}
+		return issues[i].Kind < issues[j].Kind
+	})
+	return issues
+}
+
+// checkMessage compares the fields of the message key of both definitions
+func checkMessage(before, after *Definition, pkg, key string, om, nm *pp.Message) []Incompatibility {
+	var issues []Incompatibility
+	add := func(kind, name, format string, args ...interface{}) {
+		issues = append(issues, Incompatibility{Kind: kind, Element: key + "." + name, Message: fmt.Sprintf(format, args...)})
+	}
+
+	oldFields := map[int]*fieldInfo{}
+	for _, f := range messageFields(om) {
+		oldFields[f.number] = f
+	}
+	newFields := map[int]*fieldInfo{}
+	for _, f := range messageFields(nm) {
+		newFields[f.number] = f
+	}
+	oldRequired, newRequired := requiredFields(om), requiredFields(nm)
+
+	for _, of := range messageFields(om) {
+		nf, ok := newFields[of.number]
+		if !ok {
+			switch {
+			case isReservedNumber(nm, of.number):
+			case isReservedName(nm, of.name):
+				// the name keeps JSON and text from reusing it, not the wire
+				add(IncompatibleFieldRemoved, of.name, "field %d removed, only its name is reserved", of.number)
+			default:
+				add(IncompatibleFieldRemoved, of.name, "field %d removed without reserving it", of.number)
+			}
+			continue
+		}
+
+		ow, oknown := fieldWire(before, pkg, of)
+		nw, nknown := fieldWire(after, pkg, nf)
+		wireChanged := oknown && nknown && ow != nw
+		switch {
+		case of.name != nf.name && (of.typ != nf.typ || of.keyType != nf.keyType):
+			// a renamed field keeps its type, another field reuses the number
+			add(IncompatibleNumberReused, nf.name, "field %d of %s %s reused by %s %s", of.number, fieldType(of), of.name, fieldType(nf), nf.name)
+		case wireChanged:
+			add(IncompatibleWireType, nf.name, "type changed from %s to %s (wire type %d -> %d)", fieldType(of), fieldType(nf), ow, nw)
+		}
+
+		if newRequired[nf.number] && !oldRequired[of.number] {
+			add(IncompatibleRequiredAdded, nf.name, "field %d became required", nf.number)
+		}
+	}
+
+	for _, nf := range messageFields(nm) {
+		if _, ok := oldFields[nf.number]; ok {
+			continue
+		}
+		if isReservedNumber(om, nf.number) {
+			add(IncompatibleNumberReused, nf.name, "reserved field %d reused by %s %s", nf.number, fieldType(nf), nf.name)
+		}
+		if newRequired[nf.number] {
+			add(IncompatibleRequiredAdded, nf.name, "required field %d added", nf.number)
+		}
+	}
+	return issues
+}
+
+// checkEnum compares the values of the enum key of both definitions
+func checkEnum(key string, oe, ne *pp.Enum) []Incompatibility {
+	values := map[int]bool{}
+	for _, each := range ne.Elements {
+		if v, ok := each.(*pp.EnumField); ok {
+			values[v.Integer] = true
+		}
+	}
+
+	var issues []Incompatibility
+	for _, each := range oe.Elements {
+		if v, ok := each.(*pp.EnumField); ok && !values[v.Integer] {
+			issues = append(issues, Incompatibility{
+				Kind:    IncompatibleEnumValueRemoved,
+				Element: key + "." + v.Name,
+				Message: fmt.Sprintf("value %d removed", v.Integer),
+			})
+		}
+	}
+	return issues
+}
+
+// fieldWire returns the wire type of the values of f, a field declared in
+// package pkg of d, and false if its type is not in d
+func fieldWire(d *Definition, pkg string, f *fieldInfo) (uint64, bool) {
+	if f.keyType != "" {
+		return pb.WireBytes, true
+	}
+	if wire, ok := scalarWires[f.typ]; ok {
+		return wire, true
+	}
+	if _, _, ok := d.resolveMessage(pkg, f.typ); ok {
+		return pb.WireBytes, true
+	}
+	if _, ok := d.resolveEnum(pkg, f.typ); ok {
+		return pb.WireVarint, true
+	}
+	return 0, false
+}
+
+// fieldType returns the declared type of f
+func fieldType(f *fieldInfo) string {
+	if f.keyType != "" {
+		return fmt.Sprintf("map<%s, %s>", f.keyType, f.typ)
+	}
+	if f.repeated {
+		return "repeated " + f.typ
+	}
+	return f.typ
+}
+
+// requiredFields returns the numbers of the proto2 required fields of m
+func requiredFields(m *pp.Message) map[int]bool {
+	required := map[int]bool{}
+	for _, each := range m.Elements {
+		if f, ok := each.(*pp.NormalField); ok && f.Required {
+			required[f.Sequence] = true
+		}
+	}
+	return required
+}
+
+// isReservedNumber reports whether the field number is reserved in m
+func isReservedNumber(m *pp.Message, number int) bool {
+	for _, each := range m.Elements {
+		if r, ok := each.(*pp.Reserved); ok {
+			for _, rg := range r.Ranges {
+				if number >= rg.From && (rg.Max || number <= rg.To) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// isReservedName reports whether the field name is reserved in m
+func isReservedName(m *pp.Message, name string) bool {
+	for _, each := range m.Elements {
+		if r, ok := each.(*pp.Reserved); ok {
+			for _, n := range r.FieldNames {
+				if n == name {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// packageOfKey returns the package of the message or enum key, like
+// test.v1 of test.v1.Test
+func packageOfKey(d *Definition, key string) string {
+	pkg := ""
+	for _, each := range d.filenameToPackage {
+		if len(each) > len(pkg) && strings.HasPrefix(key, each+".") {
+			pkg = each
+		}
+	}
+	return pkg
+}
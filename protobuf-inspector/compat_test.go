@@ -0,0 +1,90 @@
+package inspector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckCompatibility(t *testing.T) {
+	before := NewDefinition()
+	require.Nil(t, before.ReadFrom("order.proto", strings.NewReader(`
+syntax = "proto2";
+
+package order.v1;
+
+message Order {
+  optional int32 id = 1;
+  optional string note = 2;
+  optional Item item = 3;
+  optional int64 total = 4;
+  optional string old = 5;
+  optional string gone = 6;
+  optional Status status = 7;
+  optional string renamed = 8;
+  optional string tag = 9;
+  optional string legacy = 11;
+  optional string kept = 12;
+  reserved 20;
+}
+
+message Item {
+  optional string name = 1;
+}
+
+enum Status {
+  UNKNOWN = 0;
+  OPEN = 1;
+  CLOSED = 2;
+}
+`)))
+
+	after := NewDefinition()
+	require.Nil(t, after.ReadFrom("order.proto", strings.NewReader(`
+syntax = "proto2";
+
+package order.v1;
+
+message Order {
+  optional sint32 id = 1;
+  optional Item note = 2;
+  optional bytes item = 3;
+  optional fixed64 total = 4;
+  optional int32 recycled = 5;
+  optional int32 status = 7;
+  optional string name = 8;
+  required string tag = 9;
+  required string added = 10;
+  optional string reused = 20;
+  reserved 12 to max;
+  reserved "legacy";
+}
+
+message Item {
+  optional string name = 1;
+}
+
+enum Status {
+  UNKNOWN = 0;
+  CLOSED = 2;
+}
+`)))
+
+	// int32 to sint32 keeps the wire type and passes
+	issues := CheckCompatibility(before, after)
+	var lines []string
+	for _, issue := range issues {
+		lines = append(lines, issue.Kind+" "+issue.String())
+	}
+	require.Equal(t, []string{
+		"required field added order.v1.Order.added: required field 10 added",
+		"field removed order.v1.Order.gone: field 6 removed without reserving it",
+		"field removed order.v1.Order.legacy: field 11 removed, only its name is reserved",
+		"number reused order.v1.Order.recycled: field 5 of string old reused by int32 recycled",
+		"number reused order.v1.Order.reused: reserved field 20 reused by string reused",
+		"required field added order.v1.Order.tag: field 9 became required",
+		"wire type changed order.v1.Order.total: type changed from int64 to fixed64 (wire type 0 -> 1)",
+		"enum value removed order.v1.Status.OPEN: value 1 removed",
+	}, lines)
+}
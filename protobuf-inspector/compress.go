@@ -0,0 +1,105 @@
+package inspector
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagic   = []byte{0x1f, 0x8b}
+	zstdMagic   = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	snappyMagic = []byte("\xff\x06\x00\x00sNaPpY")
+
+	// maxDecompressedSize bounds the decompressed size against inputs which
+	// decompress to exhaust the memory
+	maxDecompressedSize = 64 << 20
+)
+
+// DetectCompression returns the codec (gzip, zstd or snappy) whose magic
+// bytes raw starts with, or "" if none does. Snappy is only detected in the
+// framing format since raw snappy blocks have no magic.
+func DetectCompression(raw []byte) string {
+	switch {
+	case bytes.HasPrefix(raw, gzipMagic):
+		return "gzip"
+	case bytes.HasPrefix(raw, zstdMagic):
+		return "zstd"
+	case bytes.HasPrefix(raw, snappyMagic):
+		return "snappy"
+	}
+	return ""
+}
+
+// Decompress decompresses raw with codec, which is one of none, gzip, zstd,
+// snappy or auto to detect it by DetectCompression. It returns the codec
+// which was applied, "" if raw was returned as is.
+func Decompress(raw []byte, codec string) ([]byte, string, error) {
+	if codec == "auto" {
+		codec = DetectCompression(raw)
+	}
+
+	var (
+		b   []byte
+		err error
+	)
+	switch codec {
+	case "", "none":
+		return raw, "", nil
+
+	case "gzip":
+		var r *gzip.Reader
+		r, err = gzip.NewReader(bytes.NewReader(raw))
+		if err == nil {
+			b, err = readDecompressed(r)
+		}
+
+	case "zstd":
+		var d *zstd.Decoder
+		d, err = zstd.NewReader(bytes.NewReader(raw))
+		if err == nil {
+			b, err = readDecompressed(d)
+			d.Close()
+		}
+
+	case "snappy":
+		if bytes.HasPrefix(raw, snappyMagic) {
+			b, err = readDecompressed(snappy.NewReader(bytes.NewReader(raw)))
+			break
+		}
+		var n int
+		if n, err = snappy.DecodedLen(raw); err == nil && n > maxDecompressedSize {
+			err = errDecompressedSize()
+		}
+		if err == nil {
+			b, err = snappy.Decode(nil, raw)
+		}
+
+	default:
+		return nil, "", fmt.Errorf("inspector: unknown compression %s", codec)
+	}
+
+	if err != nil {
+		return nil, "", fmt.Errorf("inspector: %s %v", codec, err)
+	}
+	return b, codec, nil
+}
+
+// readDecompressed reads the decompressed data from r up to
+// maxDecompressedSize
+func readDecompressed(r io.Reader) ([]byte, error) {
+	b, err := ioutil.ReadAll(io.LimitReader(r, int64(maxDecompressedSize)+1))
+	if err == nil && len(b) > maxDecompressedSize {
+		return nil, errDecompressedSize()
+	}
+	return b, err
+}
+
+func errDecompressedSize() error {
+	return fmt.Errorf("decompressed size exceeds %d bytes", maxDecompressedSize)
+}
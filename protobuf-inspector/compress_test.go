@@ -0,0 +1,94 @@
+package inspector
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecompress(t *testing.T) {
+	raw := []byte{0x0a, 0x03, 0x62, 0x6f, 0x62}
+
+	gz := bytes.NewBuffer(nil)
+	w := gzip.NewWriter(gz)
+	_, err := w.Write(raw)
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+
+	e, err := zstd.NewWriter(nil)
+	require.Nil(t, err)
+	zs := e.EncodeAll(raw, nil)
+
+	framed := bytes.NewBuffer(nil)
+	sw := snappy.NewBufferedWriter(framed)
+	_, err = sw.Write(raw)
+	require.Nil(t, err)
+	require.Nil(t, sw.Close())
+
+	tests := []struct {
+		in    []byte
+		codec string
+		want  string
+	}{
+		{raw, "auto", ""},
+		{gz.Bytes(), "auto", "gzip"},
+		{zs, "auto", "zstd"},
+		{framed.Bytes(), "auto", "snappy"},
+		{snappy.Encode(nil, raw), "snappy", "snappy"},
+		{gz.Bytes(), "none", ""},
+	}
+
+	for _, test := range tests {
+		b, codec, err := Decompress(test.in, test.codec)
+		require.Nil(t, err)
+		require.Equal(t, test.want, codec)
+		if codec != "" {
+			require.Equal(t, raw, b)
+		} else {
+			require.Equal(t, test.in, b)
+		}
+	}
+
+	_, _, err = Decompress(raw, "gzip")
+	require.NotNil(t, err)
+	_, _, err = Decompress(raw, "lz4")
+	require.Equal(t, "inspector: unknown compression lz4", err.Error())
+}
+
+func TestDecompressLimit(t *testing.T) {
+	defer func(n int) { maxDecompressedSize = n }(maxDecompressedSize)
+	maxDecompressedSize = 4
+
+	raw := []byte{0x0a, 0x03, 0x62, 0x6f, 0x62}
+
+	gz := bytes.NewBuffer(nil)
+	w := gzip.NewWriter(gz)
+	_, err := w.Write(raw)
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+
+	e, err := zstd.NewWriter(nil)
+	require.Nil(t, err)
+
+	tests := []struct {
+		in    []byte
+		codec string
+	}{
+		{gz.Bytes(), "gzip"},
+		{e.EncodeAll(raw, nil), "zstd"},
+		{snappy.Encode(nil, raw), "snappy"},
+	}
+	for _, test := range tests {
+		_, _, err := Decompress(test.in, test.codec)
+		require.Equal(t, "inspector: "+test.codec+" decompressed size exceeds 4 bytes", err.Error())
+	}
+
+	maxDecompressedSize = 5
+	b, _, err := Decompress(gz.Bytes(), "gzip")
+	require.Nil(t, err)
+	require.Equal(t, raw, b)
+}
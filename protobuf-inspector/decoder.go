@@ -1,11 +1,15 @@
 package inspector
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	pp "github.com/emicklei/proto"
 	pb "github.com/golang/protobuf/proto"
@@ -26,6 +30,43 @@ type Decoder struct {
 	b       *Buffer
 	r       map[string]interface{}
 	verbose bool
+	base    int     // offset of b within the outermost message
+	spans   []*Span // byte ranges of the decoded fields
+	cur     *Span   // span of the field being decoded
+
+	// decodeBytes decodes bytes fields which look like embedded messages
+	// without schema
+	decodeBytes bool
+}
+
+// Span is the byte range of one field decoded by the Decoder.
+// Start and End are offsets into the outermost message.
+type Span struct {
+	Name     string
+	Tag      uint64
+	Wire     uint64
+	Start    int
+	End      int
+	Values   []interface{}
+	Children []*Span
+}
+
+// InvalidUTF8 is the value of a string field which is not valid UTF-8. It
+// keeps the bytes of the field, formats them as a quoted string with the
+// invalid bytes escaped and marshals them to JSON as {"invalidUtf8": base64}
+type InvalidUTF8 string
+
+// invalidUTF8Key follows the quoted key of a map entry whose string key is
+// not valid UTF-8, which mapKey takes back to the bytes of the key
+const invalidUTF8Key = " (invalid utf-8)"
+
+func (s InvalidUTF8) String() string {
+	return strconv.Quote(string(s))
+}
+
+// MarshalJSON marshals s as an object since JSON strings cannot hold it
+func (s InvalidUTF8) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string][]byte{"invalidUtf8": []byte(s)})
 }
 
 func (d *Decoder) Decode(pkg, t string) (map[string]interface{}, error) {
@@ -33,22 +74,39 @@ func (d *Decoder) Decode(pkg, t string) (map[string]interface{}, error) {
 	if !ok {
 		return nil, fmt.Errorf("no definition found for package [%s] type [%s]", pkg, t)
 	}
+	return d.decodeMessage(pkg, m)
+}
+
+// decodeMessage decodes the fields of m, a message of package pkg, up to the
+// end of d.b
+func (d *Decoder) decodeMessage(pkg string, m *pp.Message) (map[string]interface{}, error) {
 	d.p = pkg
 	d.m = m
 	for {
+		start := d.b.index
 		op, err := d.b.DecodeVarint()
 		if err != nil {
 			break
 		}
 		tag := op >> 3
 		wire := op & 7
+		d.cur = &Span{Tag: tag, Wire: wire, Start: d.base + start}
 		if err := d.decodeTag(tag, wire); err != nil {
 			return d.r, err
 		}
+		if d.cur.Name != "" {
+			d.cur.End = d.base + d.b.index
+			d.spans = append(d.spans, d.cur)
+		}
 	}
 	return d.r, nil
 }
 
+// Spans returns the byte ranges of the fields decoded so far
+func (d *Decoder) Spans() []*Span {
+	return d.spans
+}
+
 func NewDecoder(d *Definition, b *Buffer) *Decoder {
 	return &Decoder{
 		d: d,
@@ -61,20 +119,54 @@ func (d *Decoder) decodeTag(tag, wire uint64) error {
 	for _, each := range d.m.Elements {
 		if f, ok := each.(*pp.NormalField); ok {
 			if f.Sequence == int(tag) {
+				d.cur.Name = f.Name
 				return d.decodeNormalField(f, wire)
 			}
 		}
 		if f, ok := each.(*pp.MapField); ok {
 			if f.Sequence == int(tag) {
+				d.cur.Name = f.Name
 				return d.decodeMapField(f, wire)
 			}
 		}
 		if f, ok := each.(*pp.OneOfField); ok {
 			if f.Sequence == int(tag) {
+				d.cur.Name = f.Name
 				return d.decodeOneOfField(f, wire)
 			}
 		}
+		if g, ok := each.(*pp.Group); ok {
+			if g.Sequence == int(tag) {
+				d.cur.Name = groupFieldName(g)
+				return d.decodeGroupField(g, wire)
+			}
+		}
+	}
+	return d.decodeUnknownField(tag, wire)
+}
+
+// decodeUnknownField decodes the field which is not in the definition
+// without schema and adds it by its tag number.
+func (d *Decoder) decodeUnknownField(tag, wire uint64) error {
+	name := strconv.FormatUint(tag, 10)
+	d.cur.Name = name
+	v, err := d.decodeRawValue(tag, wire)
+	if err != nil {
+		if io.ErrUnexpectedEOF == err {
+			return err
+		}
+		return fmt.Errorf("cannot decode unknown field %d:%v", tag, err)
 	}
+	if val, ok := d.r[name]; ok {
+		// the same unknown field again means it is repeated
+		if list, ok := val.([]interface{}); ok {
+			d.add(name, append(list, v), !repeatedField, !mapField)
+		} else {
+			d.add(name, []interface{}{val, v}, !repeatedField, !mapField)
+		}
+		return nil
+	}
+	d.add(name, v, !repeatedField, !mapField)
 	return nil
 }
 
@@ -106,13 +198,93 @@ func (d *Decoder) decodeNormalField(f *pp.NormalField, wire uint64) error {
 	if "bool" == f.Type {
 		return d.handleBool(f.Name, f.Repeated)
 	}
-	if _, ok := d.d.Message(d.p, f.Type); ok {
-		return d.decodeNormalFieldMessage(f)
+	if pkg, m, ok := d.d.resolveMessage(d.p, f.Type); ok {
+		return d.decodeNormalFieldMessage(f, pkg, m)
 	}
-	if e, ok := d.d.Enum(d.p, f.Type); ok {
+	if e, ok := d.d.resolveEnum(d.p, f.Type); ok {
 		return d.decodeNormalFieldEnum(f, e)
 	}
-	return fmt.Errorf("unknown type:%s", f.Type)
+	return d.decodeUnresolvedField(f, wire)
+}
+
+// decodeGroupField decodes the fields of the group g, whose start tag was
+// just read, up to its end tag.
+func (d *Decoder) decodeGroupField(g *pp.Group, wire uint64) error {
+	if wire != pb.WireStartGroup {
+		return fmt.Errorf("cannot decode group %s of wire type %d", g.Name, wire)
+	}
+
+	// the group is found by decoding it without schema first
+	start := d.b.index
+	f := &RawField{Offset: d.cur.Start, Tag: uint64(g.Sequence), Wire: wire}
+	if err := d.b.decodeRawValue(f, d.base); err != nil {
+		if _, ok := err.(truncatedError); ok {
+			return io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("cannot decode group %s:%v", g.Name, err)
+	}
+	if len(f.Children) == 0 || f.Children[len(f.Children)-1].Kind != KindEndGroup {
+		return io.ErrUnexpectedEOF
+	}
+	if end := f.Children[len(f.Children)-1]; end.Tag != f.Tag {
+		return fmt.Errorf("cannot decode group %s: end t=%d does not match start t=%d", g.Name, end.Tag, f.Tag)
+	}
+	end := f.Children[len(f.Children)-1].Offset - d.base
+
+	sub := NewDecoder(d.d, NewBuffer(d.b.buf[start:end]))
+	sub.verbose = d.verbose
+	sub.decodeBytes = d.decodeBytes
+	sub.base = d.base + start
+	defer func() { d.cur.Children = sub.spans }()
+	if _, err := sub.decodeMessage(d.p, groupMessage(g)); err != nil {
+		if io.ErrUnexpectedEOF == err {
+			return err
+		}
+		return fmt.Errorf("unable to decode group %s error:%v", g.Name, err)
+	}
+	d.add(groupFieldName(g), sub.r, g.Repeated, !mapField)
+	return nil
+}
+
+// groupMessage returns the message declared by the group g
+func groupMessage(g *pp.Group) *pp.Message {
+	return &pp.Message{Name: g.Name, Elements: g.Elements, Parent: g.Parent}
+}
+
+// groupFieldName returns the name of the field of the group g, which is
+// the name of the group in lower case
+func groupFieldName(g *pp.Group) string {
+	return strings.ToLower(g.Name)
+}
+
+// decodeRawValue decodes the value of the field whose tag was just read
+// without schema.
+func (d *Decoder) decodeRawValue(tag, wire uint64) (interface{}, error) {
+	f := &RawField{Offset: d.cur.Start, Tag: tag, Wire: wire}
+	if err := d.b.decodeRawValue(f, d.base); err != nil {
+		if _, ok := err.(truncatedError); ok {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return f.Interface(), nil
+}
+
+// decodeUnresolvedField decodes the field whose type is not in the
+// definition without schema.
+func (d *Decoder) decodeUnresolvedField(f *pp.NormalField, wire uint64) error {
+	if d.verbose {
+		log.Println("WARN:unresolved type", f.Type, "of", f.Name)
+	}
+	v, err := d.decodeRawValue(uint64(f.Sequence), wire)
+	if err != nil {
+		if io.ErrUnexpectedEOF == err {
+			return err
+		}
+		return fmt.Errorf("cannot decode %s:%s:%v", f.Name, f.Type, err)
+	}
+	d.add(f.Name, v, f.Repeated, !mapField)
+	return nil
 }
 
 func (d *Decoder) decodeNormalFieldEnum(f *pp.NormalField, e *pp.Enum) error {
@@ -131,7 +303,9 @@ func (d *Decoder) decodeNormalFieldEnum(f *pp.NormalField, e *pp.Enum) error {
 	return fmt.Errorf("unknown enum field value:%d", x)
 }
 
-func (d *Decoder) decodeNormalFieldMessage(f *pp.NormalField) error {
+// decodeNormalFieldMessage decodes the field f of type m, a message of
+// package pkg
+func (d *Decoder) decodeNormalFieldMessage(f *pp.NormalField, pkg string, m *pp.Message) error {
 	nextData, err := d.b.DecodeRawBytes(true)
 	if err != nil {
 		if io.ErrUnexpectedEOF == err {
@@ -139,54 +313,43 @@ func (d *Decoder) decodeNormalFieldMessage(f *pp.NormalField) error {
 		}
 		return fmt.Errorf("unable or read raw bytes of message of type:%s", f.Type)
 	}
-	sub := NewDecoder(d.d, NewBuffer(nextData))
-	if f.Repeated {
-		for {
-			if d.verbose {
-				log.Println("BEGIN repeated", f.Name, ":", f.Type)
-			}
-			if _, err := sub.Decode(d.p, f.Type); err != nil { // what package?
-				if io.ErrUnexpectedEOF == err {
-					if d.verbose {
-						log.Println("END", f.Name, ":", f.Type)
-					}
-					// TODO?????
-					d.add(f.Name, sub.r, repeatedField, !mapField)
-					break
-				}
-				if ErrEndOfMessage == err {
-					// TODO?????
-					d.add(f.Name, sub.r, repeatedField, !mapField)
-					break
-				}
-				return fmt.Errorf("unable to decode repeated message of type:%v error:%v", f.Type, err)
-			}
-			d.add(f.Name, sub.r, repeatedField, !mapField)
-		}
-	} else {
-		// single
-		if d.verbose {
-			log.Println("BEGIN single", f.Name, ":", f.Type)
-		}
-		if _, err := sub.Decode(d.p, f.Type); err != nil { // what package
-			if io.ErrUnexpectedEOF == err || ErrEndOfMessage == err {
-				if d.verbose {
-					log.Println("END", f.Name, ":", f.Type)
-				}
-				d.add(f.Name, sub.r, !repeatedField, !mapField)
-			} else {
-				return fmt.Errorf("unable to decode single message of type:%v error:%v", f.Type, err)
-			}
+	sub := d.newSubDecoder(nextData)
+	defer func() { d.cur.Children = sub.spans }()
+	// each element of a repeated message is encoded as its own field, so
+	// there is one message to decode, not a loop which never ends as Decode
+	// returns nil at the end of data
+	if d.verbose {
+		log.Println("BEGIN", f.Name, ":", f.Type)
+	}
+	if _, err := sub.decodeMessage(pkg, m); err != nil {
+		if io.ErrUnexpectedEOF != err && ErrEndOfMessage != err {
+			return fmt.Errorf("unable to decode message of type:%v error:%v", f.Type, err)
 		}
-		d.add(f.Name, sub.r, !repeatedField, !mapField)
 	}
+	if d.verbose {
+		log.Println("END", f.Name, ":", f.Type)
+	}
+	d.add(f.Name, sub.r, f.Repeated, !mapField)
 	return nil
 }
 
+// newSubDecoder returns a decoder for the embedded message data which was
+// just read from d.b, keeping its spans relative to the outermost message.
+func (d *Decoder) newSubDecoder(data []byte) *Decoder {
+	sub := NewDecoder(d.d, NewBuffer(data))
+	sub.verbose = d.verbose
+	sub.decodeBytes = d.decodeBytes
+	sub.base = d.base + d.b.index - len(data)
+	return sub
+}
+
 func (d *Decoder) add(key string, value interface{}, repeated bool, isMap bool) {
 	if d.verbose {
 		log.Printf("[%s] add [%s=%v] repeated:%v map:%v\n", d.m.Name, key, value, repeated, isMap)
 	}
+	if d.cur != nil {
+		d.cur.Values = append(d.cur.Values, value)
+	}
 	if repeated {
 		if val, ok := d.r[key]; ok {
 			maps := val.([]interface{})
@@ -254,14 +417,20 @@ func (d *Decoder) decodeMapField(f *pp.MapField, wire uint64) error {
 		}
 		return fmt.Errorf("unable to read raw bytes of map of type:%s", f.Type)
 	}
-	sub := NewDecoder(d.d, NewBuffer(nextData))
+	sub := d.newSubDecoder(nextData)
+	defer func() { d.cur.Children = sub.spans }()
 	result, err := sub.Decode(d.p, entryMessageName)
 	if err != nil && err != ErrEndOfMessage {
 		return fmt.Errorf("unable to decode map of type:%s->%s err:%v", f.KeyType, f.Type, err)
 	}
 	// TODO
 	// Golang cannot JSON marshal map[interface{}]interface{} so we convert the key to a string
-	if "string" == f.KeyType {
+	if key, ok := result["key"].(InvalidUTF8); ok {
+		// keyed like the maps which are not keyed by strings
+		mapResult := map[string]interface{}{}
+		mapResult[key.String()+invalidUTF8Key] = result["value"]
+		d.add(f.Name, mapResult, !repeatedField, mapField)
+	} else if "string" == f.KeyType {
 		// one of the repeated
 		mapResult := map[string]interface{}{}
 		mapResult[result["key"].(string)] = result["value"]
@@ -452,27 +621,17 @@ func (d *Decoder) handleString(n string, repeated bool) error {
 		}
 		return fmt.Errorf("cannot decode %s:string:%v", n, err)
 	}
+	if !utf8.ValidString(sb) {
+		d.add(n, InvalidUTF8(sb), repeated, !mapField)
+		return nil
+	}
 	d.add(n, string(sb), repeated, !mapField)
 	return nil
 }
 
 func (d *Decoder) handleBytes(n string, repeated bool) error {
-	if repeated {
-		data, err := d.b.DecodeRawBytes(true)
-		if err != nil {
-			return fmt.Errorf("cannot decode repeated bytes raw bytes:%v", err)
-		}
-		buf := pb.NewBuffer(data)
-		for {
-			x, err := buf.DecodeRawBytes(true)
-			if err == io.ErrUnexpectedEOF {
-				break
-			}
-			d.add(n, x, repeated, !mapField)
-		}
-		return nil
-	}
-	// non-repeated
+	// non-repeated and repeated: unlike scalars, bytes are never packed and
+	// each element is encoded as its own field
 	x, err := d.b.DecodeRawBytes(true)
 	if err != nil {
 		if io.ErrUnexpectedEOF == err {
@@ -480,7 +639,12 @@ func (d *Decoder) handleBytes(n string, repeated bool) error {
 		}
 		return fmt.Errorf("cannot decode %s:bytes:%v", n, err)
 	}
-	d.add(n, x, !repeatedField, !mapField)
+	if d.decodeBytes && isMessage(x) && !isText(x) {
+		fields, _ := NewBuffer(x).decodeRawFields(d.base+d.b.index-len(x), nil)
+		d.add(n, rawFieldsToMap(fields), repeated, !mapField)
+		return nil
+	}
+	d.add(n, x, repeated, !mapField)
 	return nil
 }
 
@@ -15,6 +15,7 @@ import (
 type Definition struct {
 	messages          map[string]*pp.Message
 	enums             map[string]*pp.Enum
+	services          map[string]*pp.Service
 	filenamesRead     []string
 	filenameToPackage map[string]string
 }
@@ -23,6 +24,7 @@ func NewDefinition() *Definition {
 	return &Definition{
 		messages:          map[string]*pp.Message{},
 		enums:             map[string]*pp.Enum{},
+		services:          map[string]*pp.Service{},
 		filenamesRead:     []string{},
 		filenameToPackage: map[string]string{},
 	}
@@ -66,6 +68,9 @@ func (d *Definition) ReadFrom(filename string, reader io.Reader) error {
 	pp.Walk(def, pp.WithEnum(func(each *pp.Enum) {
 		d.AddEnum(pkg, each.Name, each)
 	}))
+	pp.Walk(def, pp.WithService(func(each *pp.Service) {
+		d.AddService(pkg, each.Name, each)
+	}))
 	return nil
 }
 
@@ -99,6 +104,128 @@ func (d *Definition) Enum(pkg string, name string) (e *pp.Enum, ok bool) {
 	return
 }
 
+// Service returns the service
+func (d *Definition) Service(pkg string, name string) (s *pp.Service, ok bool) {
+	key := fmt.Sprintf("%s.%s", pkg, name)
+	s, ok = d.services[key]
+	return
+}
+
+// Method returns the rpc of method named like pkg.Service/Method, or
+// /pkg.Service/Method as the :path of gRPC requests, and the package of
+// its service
+func (d *Definition) Method(method string) (pkg string, rpc *pp.RPC, ok bool) {
+	parts := strings.Split(strings.TrimPrefix(method, "/"), "/")
+	if len(parts) != 2 {
+		return "", nil, false
+	}
+
+	s, ok := d.services[parts[0]]
+	if !ok {
+		return "", nil, false
+	}
+	if i := strings.LastIndex(parts[0], "."); i >= 0 {
+		pkg = parts[0][:i]
+	}
+
+	for _, each := range s.Elements {
+		if r, ok := each.(*pp.RPC); ok && r.Name == parts[1] {
+			return pkg, r, true
+		}
+	}
+	return "", nil, false
+}
+
+// MethodMessage returns the package and name of the request or response
+// message of method
+func (d *Definition) MethodMessage(method string, response bool) (string, string, error) {
+	pkg, rpc, ok := d.Method(method)
+	if !ok {
+		return "", "", fmt.Errorf("inspector: unknown method %s", method)
+	}
+
+	typ := rpc.RequestType
+	if response {
+		typ = rpc.ReturnsType
+	}
+
+	pkg, name, ok := d.resolve(pkg, typ, func(key string) bool {
+		_, ok := d.messages[key]
+		return ok
+	})
+	if !ok {
+		return "", "", fmt.Errorf("inspector: unknown message %s of method %s", strings.TrimPrefix(typ, "."), method)
+	}
+	return pkg, name, nil
+}
+
+// resolveMessage returns the message typ referred to from package pkg and
+// the package it belongs to
+func (d *Definition) resolveMessage(pkg, typ string) (string, *pp.Message, bool) {
+	pkg, name, ok := d.resolve(pkg, typ, func(key string) bool {
+		_, ok := d.messages[key]
+		return ok
+	})
+	if !ok {
+		return "", nil, false
+	}
+	m, _ := d.Message(pkg, name)
+	return pkg, m, true
+}
+
+// resolveEnum returns the enum typ referred to from package pkg
+func (d *Definition) resolveEnum(pkg, typ string) (*pp.Enum, bool) {
+	pkg, name, ok := d.resolve(pkg, typ, func(key string) bool {
+		_, ok := d.enums[key]
+		return ok
+	})
+	if !ok {
+		return nil, false
+	}
+	return d.Enum(pkg, name)
+}
+
+// resolve splits the type typ referred to from package pkg, which is
+// relative, qualified or nested like Outer.Inner, into the package and name
+// it is registered by
+func (d *Definition) resolve(pkg, typ string, has func(key string) bool) (string, string, bool) {
+	var keys []string
+	if strings.HasPrefix(typ, ".") {
+		typ = typ[1:]
+		keys = []string{typ}
+		// nested types are registered by their own name in their package,
+		// which is one of the prefixes of typ
+		if i := strings.LastIndex(typ, "."); i >= 0 {
+			for j := strings.LastIndex(typ[:i], "."); j >= 0; j = strings.LastIndex(typ[:j], ".") {
+				keys = append(keys, typ[:j]+typ[i:])
+			}
+		}
+	} else {
+		keys = []string{fmt.Sprintf("%s.%s", pkg, typ), typ}
+		// nested types are registered by their own name only
+		if i := strings.LastIndex(typ, "."); i >= 0 {
+			keys = append(keys, fmt.Sprintf("%s.%s", pkg, typ[i+1:]))
+		}
+	}
+
+	for _, key := range keys {
+		if has(key) {
+			i := strings.LastIndex(key, ".")
+			if i < 0 {
+				return "", key, true
+			}
+			return key[:i], key[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// AddService adds the service
+func (d *Definition) AddService(pkg string, name string, service *pp.Service) {
+	key := fmt.Sprintf("%s.%s", pkg, name)
+	d.services[key] = service
+}
+
 // AddEnum adds the Enum
 func (d *Definition) AddEnum(pkg string, name string, enu *pp.Enum) {
 	key := fmt.Sprintf("%s.%s", pkg, name)
@@ -0,0 +1,313 @@
+package inspector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	pp "github.com/emicklei/proto"
+)
+
+// The kinds of a Change
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// Change is one difference between two decoded messages: the field at
+// Path, like test2.name, items[1] or attrs["k"], was added, removed or
+// changed from Old to New
+type Change struct {
+	Path string      `json:"path"`
+	Kind string      `json:"kind"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// Diff compares two messages decoded without schema, like the maps of
+// RawMessage.ToMap keyed by tag numbers, and returns their differences
+// in field order
+func Diff(a, b map[string]interface{}) []Change {
+	df := &differ{}
+	df.message("", nil, a, b, "")
+	return df.changes
+}
+
+// DiffWithDefinition compares two messages pkg.name decoded by d and
+// returns their differences in field order. Map fields are compared by
+// key and repeated fields element by element.
+func DiffWithDefinition(d *Definition, pkg, name string, a, b map[string]interface{}) ([]Change, error) {
+	m, ok := d.Message(pkg, name)
+	if !ok {
+		return nil, fmt.Errorf("no definition found for package [%s] type [%s]", pkg, name)
+	}
+	df := &differ{d: d}
+	df.message(pkg, m, a, b, "")
+	return df.changes, nil
+}
+
+// WriteChanges writes changes as text lines to w: + for added fields,
+// - for removed ones and ~ for changed ones
+func WriteChanges(w io.Writer, changes []Change) {
+	for _, c := range changes {
+		switch c.Kind {
+		case ChangeAdded:
+			fmt.Fprintf(w, "+ %s: %s\n", c.Path, formatValue(c.New))
+		case ChangeRemoved:
+			fmt.Fprintf(w, "- %s: %s\n", c.Path, formatValue(c.Old))
+		default:
+			fmt.Fprintf(w, "~ %s: %s -> %s\n", c.Path, formatValue(c.Old), formatValue(c.New))
+		}
+	}
+}
+
+// MarshalJSON marshals the values of c, with the floats which are not
+// finite as protojson does
+func (c Change) MarshalJSON() ([]byte, error) {
+	type change Change
+	return json.Marshal(change{Path: c.Path, Kind: c.Kind, Old: jsonFloats(c.Old), New: jsonFloats(c.New)})
+}
+
+// formatValue formats a decoded value as JSON, following the
+// protojson conventions for bytes and the floats which are not finite
+func formatValue(v interface{}) string {
+	b, err := json.Marshal(jsonFloats(v))
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// jsonFloats returns v with the floats which are not finite, which JSON
+// numbers cannot hold, as the strings "NaN", "Infinity" and "-Infinity"
+func jsonFloats(v interface{}) interface{} {
+	switch x := v.(type) {
+	case float32:
+		return jsonFloats(float64(x))
+	case float64:
+		switch {
+		case math.IsNaN(x):
+			return "NaN"
+		case math.IsInf(x, 1):
+			return "Infinity"
+		case math.IsInf(x, -1):
+			return "-Infinity"
+		}
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(x))
+		for k, each := range x {
+			m[k] = jsonFloats(each)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(x))
+		for i, each := range x {
+			l[i] = jsonFloats(each)
+		}
+		return l
+	}
+	return v
+}
+
+type differ struct {
+	d       *Definition // nil without schema
+	changes []Change
+}
+
+func (df *differ) add(kind, path string, old, new interface{}) {
+	df.changes = append(df.changes, Change{Path: path, Kind: kind, Old: old, New: new})
+}
+
+// message compares the fields of a and b, messages m of package pkg or
+// messages without schema if m is nil
+func (df *differ) message(pkg string, m *pp.Message, a, b map[string]interface{}, path string) {
+	fields := map[string]*fieldInfo{}
+	if m != nil {
+		for _, f := range messageFields(m) {
+			fields[f.name] = f
+		}
+	}
+
+	// the field number of a key, tag numbers of unknown fields included
+	number := func(key string) int {
+		if f, ok := fields[key]; ok {
+			return f.number
+		}
+		if n, err := strconv.Atoi(key); err == nil {
+			return n
+		}
+		return math.MaxInt32
+	}
+
+	var keys []string
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if ni, nj := number(keys[i]), number(keys[j]); ni != nj {
+			return ni < nj
+		}
+		return keys[i] < keys[j]
+	})
+
+	for _, k := range keys {
+		va, oka := a[k]
+		vb, okb := b[k]
+		switch {
+		case !okb:
+			df.add(ChangeRemoved, joinPath(path, k), va, nil)
+		case !oka:
+			df.add(ChangeAdded, joinPath(path, k), nil, vb)
+		default:
+			df.field(pkg, fields[k], va, vb, path, k)
+		}
+	}
+}
+
+// field compares the values of the field named name, which is f or unknown
+// to the definition if f is nil
+func (df *differ) field(pkg string, f *fieldInfo, a, b interface{}, path, name string) {
+	typ := ""
+	if f != nil {
+		typ = f.typ
+	}
+
+	if f != nil && f.keyType != "" {
+		ma, oka := a.(map[string]interface{})
+		mb, okb := b.(map[string]interface{})
+		if oka && okb {
+			df.entries(pkg, typ, ma, mb, path, name)
+			return
+		}
+	}
+
+	la, oka := a.([]interface{})
+	lb, okb := b.([]interface{})
+	if oka || okb {
+		// a field which occurs once is not a list without schema
+		if !oka {
+			la = []interface{}{a}
+		}
+		if !okb {
+			lb = []interface{}{b}
+		}
+		for i := 0; i < len(la) || i < len(lb); i++ {
+			p := joinPath(path, pathSegment{name: name, selector: selectIndex, index: i}.String())
+			switch {
+			case i >= len(lb):
+				df.add(ChangeRemoved, p, la[i], nil)
+			case i >= len(la):
+				df.add(ChangeAdded, p, nil, lb[i])
+			default:
+				df.value(pkg, typ, la[i], lb[i], p)
+			}
+		}
+		return
+	}
+
+	df.value(pkg, typ, a, b, joinPath(path, name))
+}
+
+// entries compares the entries of a map field by key
+func (df *differ) entries(pkg, typ string, a, b map[string]interface{}, path, name string) {
+	var keys []string
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return lessMapKey(keys[i], keys[j]) })
+
+	for _, k := range keys {
+		p := joinPath(path, mapKeySegment(name, k))
+		va, oka := a[k]
+		vb, okb := b[k]
+		switch {
+		case !okb:
+			df.add(ChangeRemoved, p, va, nil)
+		case !oka:
+			df.add(ChangeAdded, p, nil, vb)
+		default:
+			df.value(pkg, typ, va, vb, p)
+		}
+	}
+}
+
+// value compares two values of type typ, descending into embedded messages
+func (df *differ) value(pkg, typ string, a, b interface{}, path string) {
+	ma, oka := a.(map[string]interface{})
+	mb, okb := b.(map[string]interface{})
+	if oka && okb {
+		var m *pp.Message
+		if df.d != nil && typ != "" {
+			if mpkg, sub, ok := df.d.resolveMessage(pkg, typ); ok {
+				pkg, m = mpkg, sub
+			}
+		}
+		df.message(pkg, m, ma, mb, path)
+		return
+	}
+	if !equalValues(a, b) {
+		df.add(ChangeChanged, path, a, b)
+	}
+}
+
+// equalValues reports whether a and b are equal, comparing floats bitwise
+// so that NaN equals itself
+func equalValues(a, b interface{}) bool {
+	switch x := a.(type) {
+	case float32:
+		y, ok := b.(float32)
+		return ok && math.Float32bits(x) == math.Float32bits(y)
+	case float64:
+		y, ok := b.(float64)
+		return ok && math.Float64bits(x) == math.Float64bits(y)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// lessMapKey orders the keys of a map field: numbers, which the decoder
+// formats like "10 (int32)", by value and other keys as strings
+func lessMapKey(a, b string) bool {
+	na, nb := a, b
+	if i := strings.LastIndex(a, " ("); i > 0 {
+		na = a[:i]
+	}
+	if i := strings.LastIndex(b, " ("); i > 0 {
+		nb = b[:i]
+	}
+	if x, err := strconv.ParseInt(na, 10, 64); err == nil {
+		if y, err := strconv.ParseInt(nb, 10, 64); err == nil && x != y {
+			return x < y
+		}
+	}
+	if x, err := strconv.ParseUint(na, 10, 64); err == nil {
+		if y, err := strconv.ParseUint(nb, 10, 64); err == nil && x != y {
+			return x < y
+		}
+	}
+	return a < b
+}
+
+// mapKeySegment formats the key k of the map field name as a path segment.
+// The decoder keys the maps which are not keyed by strings like "1 (int32)".
+func mapKeySegment(name, k string) string {
+	if i := strings.LastIndex(k, " ("); i > 0 && strings.HasSuffix(k, ")") {
+		return fmt.Sprintf("%s[%s]", name, k[:i])
+	}
+	return pathSegment{name: name, selector: selectKey, key: k}.String()
+}
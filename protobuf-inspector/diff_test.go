@@ -0,0 +1,155 @@
+package inspector
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffWithSchema(t *testing.T) {
+	schema := `
+syntax = "proto3";
+
+package envelope.v1;
+
+message Envelope {
+  string name = 1;
+  repeated Item items = 2;
+  map<string, Item> attrs = 3;
+  map<int32, string> codes = 4;
+  bytes blob = 5;
+}
+
+message Item {
+  int32 id = 1;
+  string name = 2;
+}
+`
+	in := NewInspector()
+	require.Nil(t, in.ReadSchemaFromReader("envelope.proto", strings.NewReader(schema)))
+
+	a, err := in.EncodeJSON("envelope.v1", "Envelope", []byte(`{
+  "name": "a",
+  "items": [{"id": 1}, {"id": 2}, {"id": 3}],
+  "attrs": {"k": {"id": 1, "name": "x"}, "gone": {}},
+  "codes": {"1": "one"},
+  "blob": "AAE="
+}`))
+	require.Nil(t, err)
+	// the same message with its fields in another order
+	b, err := in.EncodeJSON("envelope.v1", "Envelope", []byte(`{
+  "blob": "AAE=",
+  "codes": {"1": "one"},
+  "attrs": {"gone": {}, "k": {"name": "x", "id": 1}},
+  "items": [{"id": 1}, {"id": 2}, {"id": 3}],
+  "name": "a"
+}`))
+	require.Nil(t, err)
+
+	changes, err := in.DiffWithSchema("envelope.v1", "Envelope", a, b)
+	require.Nil(t, err)
+	require.Empty(t, changes)
+
+	b, err = in.EncodeJSON("envelope.v1", "Envelope", []byte(`{
+  "items": [{"id": 1}, {"id": 5}],
+  "attrs": {"k": {"id": 1, "name": "y"}, "new": {"id": 2}},
+  "codes": {"1": "uno", "2": "two"},
+  "blob": "AAI="
+}`))
+	require.Nil(t, err)
+
+	changes, err = in.DiffWithSchema("envelope.v1", "Envelope", a, b)
+	require.Nil(t, err)
+	require.Equal(t, []Change{
+		{Path: "name", Kind: ChangeRemoved, Old: "a"},
+		{Path: "items[1].id", Kind: ChangeChanged, Old: int32(2), New: int32(5)},
+		{Path: "items[2]", Kind: ChangeRemoved, Old: map[string]interface{}{"id": int32(3)}},
+		{Path: `attrs["gone"]`, Kind: ChangeRemoved, Old: map[string]interface{}{}},
+		{Path: `attrs["k"].name`, Kind: ChangeChanged, Old: "x", New: "y"},
+		{Path: `attrs["new"]`, Kind: ChangeAdded, New: map[string]interface{}{"id": int32(2)}},
+		{Path: "codes[1]", Kind: ChangeChanged, Old: "one", New: "uno"},
+		{Path: "codes[2]", Kind: ChangeAdded, New: "two"},
+		{Path: "blob", Kind: ChangeChanged, Old: []byte{0, 1}, New: []byte{0, 2}},
+	}, changes)
+
+	w := bytes.NewBuffer(nil)
+	WriteChanges(w, changes[:2])
+	WriteChanges(w, changes[5:6])
+	WriteChanges(w, changes[8:])
+	require.Equal(t, `- name: "a"
+~ items[1].id: 2 -> 5
++ attrs["new"]: {"id":2}
+~ blob: "AAE=" -> "AAI="
+`, w.String())
+
+	_, err = DiffWithDefinition(in.definition, "envelope.v1", "Nope", nil, nil)
+	require.NotNil(t, err)
+}
+
+func TestDiffWithoutSchema(t *testing.T) {
+	a := []byte{
+		0x08, 0x01,
+		0x12, 0x02, 0x08, 0x01,
+		0x18, 0x01, 0x18, 0x02,
+	}
+	b := []byte{
+		0x18, 0x01, 0x18, 0x03, 0x18, 0x04,
+		0x12, 0x02, 0x08, 0x02,
+		0x25, 0x01, 0x00, 0x00, 0x00,
+	}
+
+	changes, err := NewInspector().DiffWithoutSchema(a, b)
+	require.Nil(t, err)
+	require.Equal(t, []Change{
+		{Path: "1", Kind: ChangeRemoved, Old: uint64(1)},
+		{Path: "2.1", Kind: ChangeChanged, Old: uint64(1), New: uint64(2)},
+		{Path: "3[1]", Kind: ChangeChanged, Old: uint64(2), New: uint64(3)},
+		{Path: "3[2]", Kind: ChangeAdded, New: uint64(4)},
+		{Path: "4", Kind: ChangeAdded, New: uint32(1)},
+	}, changes)
+}
+
+func TestDiffFloatsAndMapKeys(t *testing.T) {
+	schema := `
+syntax = "proto3";
+
+package envelope.v1;
+
+message Envelope {
+  double ratio = 1;
+  map<int32, string> codes = 2;
+}
+`
+	in := NewInspector()
+	require.Nil(t, in.ReadSchemaFromReader("envelope.proto", strings.NewReader(schema)))
+
+	a, err := in.EncodeJSON("envelope.v1", "Envelope", []byte(`{"ratio": "NaN", "codes": {"2": "a", "10": "b"}}`))
+	require.Nil(t, err)
+	b, err := in.EncodeJSON("envelope.v1", "Envelope", []byte(`{"ratio": "NaN", "codes": {"2": "x", "10": "y"}}`))
+	require.Nil(t, err)
+
+	// NaN equals itself and numeric keys are ordered by value
+	changes, err := in.DiffWithSchema("envelope.v1", "Envelope", a, b)
+	require.Nil(t, err)
+	require.Equal(t, []Change{
+		{Path: "codes[2]", Kind: ChangeChanged, Old: "a", New: "x"},
+		{Path: "codes[10]", Kind: ChangeChanged, Old: "b", New: "y"},
+	}, changes)
+
+	b, err = in.EncodeJSON("envelope.v1", "Envelope", []byte(`{"ratio": "-Infinity", "codes": {"2": "a", "10": "b"}}`))
+	require.Nil(t, err)
+	changes, err = in.DiffWithSchema("envelope.v1", "Envelope", a, b)
+	require.Nil(t, err)
+	require.Len(t, changes, 1)
+
+	data, err := json.Marshal(changes)
+	require.Nil(t, err)
+	require.Equal(t, `[{"path":"ratio","kind":"changed","old":"NaN","new":"-Infinity"}]`, string(data))
+
+	w := bytes.NewBuffer(nil)
+	WriteChanges(w, changes)
+	require.Equal(t, `~ ratio: "NaN" -> "-Infinity"`+"\n", w.String())
+}
@@ -0,0 +1,615 @@
+package inspector
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	pp "github.com/emicklei/proto"
+	pb "github.com/golang/protobuf/proto"
+)
+
+// scalarWires maps the scalar proto types to their wire types
+var scalarWires = map[string]uint64{
+	"int32":    pb.WireVarint,
+	"int64":    pb.WireVarint,
+	"uint32":   pb.WireVarint,
+	"uint64":   pb.WireVarint,
+	"sint32":   pb.WireVarint,
+	"sint64":   pb.WireVarint,
+	"bool":     pb.WireVarint,
+	"fixed32":  pb.WireFixed32,
+	"sfixed32": pb.WireFixed32,
+	"float":    pb.WireFixed32,
+	"fixed64":  pb.WireFixed64,
+	"sfixed64": pb.WireFixed64,
+	"double":   pb.WireFixed64,
+	"string":   pb.WireBytes,
+	"bytes":    pb.WireBytes,
+}
+
+// EncodeError is an error encoding the field at Path, like test2.name,
+// items[1].id or attrs["k"]
+type EncodeError struct {
+	Path string
+	Err  error
+}
+
+func (e *EncodeError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("inspector: %v", e.Err)
+	}
+	return fmt.Sprintf("inspector: %s: %v", e.Path, e.Err)
+}
+
+// Encoder encodes messages to protobuf binary data by a Definition
+type Encoder struct {
+	d *Definition
+}
+
+func NewEncoder(d *Definition) *Encoder {
+	return &Encoder{d: d}
+}
+
+// fieldInfo is a field of a message as needed to encode it
+type fieldInfo struct {
+	name     string
+	jsonName string
+	typ      string
+	keyType  string // of map fields
+	number   int
+	repeated bool
+	packed   bool // whether it is packed if its type can be
+}
+
+// EncodeJSON encodes data, a JSON object following the protojson
+// conventions, as the message pkg.name
+func (e *Encoder) EncodeJSON(pkg, name string, data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, &EncodeError{Err: err}
+	}
+	if dec.More() {
+		return nil, &EncodeError{Err: fmt.Errorf("unexpected data after the JSON object")}
+	}
+	return e.Encode(pkg, name, v)
+}
+
+// Encode encodes v as the message pkg.name. v is a tree of the values
+// decoded by encoding/json with UseNumber, where bytes may be []byte
+// instead of base64 strings.
+func (e *Encoder) Encode(pkg, name string, v interface{}) ([]byte, error) {
+	m, ok := e.d.Message(pkg, name)
+	if !ok {
+		return nil, fmt.Errorf("no definition found for package [%s] type [%s]", pkg, name)
+	}
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, &EncodeError{Err: fmt.Errorf("expected object, got %s", jsonType(v))}
+	}
+	return e.encodeMessage(pkg, m, obj, "")
+}
+
+func (e *Encoder) encodeMessage(pkg string, m *pp.Message, obj map[string]interface{}, path string) ([]byte, error) {
+	b := pb.NewBuffer(nil)
+	seen := map[string]bool{}
+
+	for _, f := range messageFields(m) {
+		key := f.jsonName
+		v, ok := obj[key]
+		if w, wok := obj[f.name]; wok && f.name != key {
+			if ok {
+				return nil, &EncodeError{Path: joinPath(path, f.name), Err: fmt.Errorf("duplicate field %s and %s", f.name, key)}
+			}
+			key, v, ok = f.name, w, true
+		}
+		if !ok {
+			continue
+		}
+		seen[key] = true
+		if v == nil {
+			// null is the default value
+			continue
+		}
+		if err := e.encodeField(b, pkg, f, v, joinPath(path, f.name)); err != nil {
+			return nil, err
+		}
+	}
+
+	var unknown []string
+	for k := range obj {
+		if !seen[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, &EncodeError{Path: joinPath(path, unknown[0]), Err: fmt.Errorf("unknown field of %s", m.Name)}
+	}
+	return b.Bytes(), nil
+}
+
+func (e *Encoder) encodeField(b *pb.Buffer, pkg string, f *fieldInfo, v interface{}, path string) error {
+	if f.keyType != "" {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return &EncodeError{Path: path, Err: fmt.Errorf("expected object, got %s", jsonType(v))}
+		}
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			epath := fmt.Sprintf("%s[%s]", path, strconv.Quote(k))
+			key, err := mapKey(f.keyType, k)
+			if err != nil {
+				return &EncodeError{Path: epath, Err: err}
+			}
+			entry := pb.NewBuffer(nil)
+			if err := e.encodeValue(entry, pkg, 1, f.keyType, key, epath); err != nil {
+				return err
+			}
+			if obj[k] != nil {
+				if err := e.encodeValue(entry, pkg, 2, f.typ, obj[k], epath); err != nil {
+					return err
+				}
+			}
+			b.EncodeVarint(uint64(f.number)<<3 | pb.WireBytes)
+			b.EncodeRawBytes(entry.Bytes())
+		}
+		return nil
+	}
+
+	if !f.repeated {
+		return e.encodeValue(b, pkg, f.number, f.typ, v, path)
+	}
+
+	list, ok := v.([]interface{})
+	if !ok {
+		return &EncodeError{Path: path, Err: fmt.Errorf("expected array, got %s", jsonType(v))}
+	}
+	if f.packed && e.packable(pkg, f.typ) {
+		packed := pb.NewBuffer(nil)
+		for i, each := range list {
+			if err := e.appendValue(packed, pkg, f.typ, each, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		if len(list) > 0 {
+			b.EncodeVarint(uint64(f.number)<<3 | pb.WireBytes)
+			b.EncodeRawBytes(packed.Bytes())
+		}
+		return nil
+	}
+	for i, each := range list {
+		if err := e.encodeValue(b, pkg, f.number, f.typ, each, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// packable reports whether repeated fields of typ can be packed
+func (e *Encoder) packable(pkg, typ string) bool {
+	if wire, ok := scalarWires[typ]; ok {
+		return wire != pb.WireBytes
+	}
+	_, ok := e.d.resolveEnum(pkg, typ)
+	return ok
+}
+
+// encodeValue appends the tag and the value v of the field number of type typ
+func (e *Encoder) encodeValue(b *pb.Buffer, pkg string, number int, typ string, v interface{}, path string) error {
+	wire, ok := scalarWires[typ]
+	if !ok {
+		if _, _, ok := e.d.resolveMessage(pkg, typ); ok {
+			wire = pb.WireBytes
+		} else {
+			wire = pb.WireVarint
+		}
+	}
+	b.EncodeVarint(uint64(number)<<3 | wire)
+	return e.appendValue(b, pkg, typ, v, path)
+}
+
+// appendValue appends the value v of type typ without tag
+func (e *Encoder) appendValue(b *pb.Buffer, pkg, typ string, v interface{}, path string) error {
+	if _, ok := scalarWires[typ]; ok {
+		if err := appendScalar(b, typ, v); err != nil {
+			return &EncodeError{Path: path, Err: err}
+		}
+		return nil
+	}
+
+	if mpkg, m, ok := e.d.resolveMessage(pkg, typ); ok {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return &EncodeError{Path: path, Err: fmt.Errorf("expected object, got %s", jsonType(v))}
+		}
+		data, err := e.encodeMessage(mpkg, m, obj, path)
+		if err != nil {
+			return err
+		}
+		b.EncodeRawBytes(data)
+		return nil
+	}
+
+	if en, ok := e.d.resolveEnum(pkg, typ); ok {
+		n, err := enumValue(en, v)
+		if err != nil {
+			return &EncodeError{Path: path, Err: err}
+		}
+		b.EncodeVarint(uint64(int64(n)))
+		return nil
+	}
+
+	return &EncodeError{Path: path, Err: fmt.Errorf("unresolved type %s", typ)}
+}
+
+// appendScalar appends the value v of the scalar type typ
+func appendScalar(b *pb.Buffer, typ string, v interface{}) error {
+	switch typ {
+	case "int32", "sint32", "sfixed32":
+		n, err := toInt(v, 32)
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case "int32":
+			b.EncodeVarint(uint64(n))
+		case "sint32":
+			b.EncodeZigzag32(uint64(n))
+		default:
+			b.EncodeFixed32(uint64(uint32(n)))
+		}
+
+	case "int64", "sint64", "sfixed64":
+		n, err := toInt(v, 64)
+		if err != nil {
+			return err
+		}
+		switch typ {
+		case "int64":
+			b.EncodeVarint(uint64(n))
+		case "sint64":
+			b.EncodeZigzag64(uint64(n))
+		default:
+			b.EncodeFixed64(uint64(n))
+		}
+
+	case "uint32", "fixed32", "uint64", "fixed64":
+		bits := 64
+		if strings.HasSuffix(typ, "32") {
+			bits = 32
+		}
+		n, err := toUint(v, bits)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(typ, "fixed") && bits == 32 {
+			b.EncodeFixed32(n)
+		} else if strings.HasPrefix(typ, "fixed") {
+			b.EncodeFixed64(n)
+		} else {
+			b.EncodeVarint(n)
+		}
+
+	case "bool":
+		x, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("expected bool, got %s", jsonType(v))
+		}
+		if x {
+			b.EncodeVarint(1)
+		} else {
+			b.EncodeVarint(0)
+		}
+
+	case "float":
+		f, err := toFloat(v, 32)
+		if err != nil {
+			return err
+		}
+		b.EncodeFixed32(uint64(math.Float32bits(float32(f))))
+
+	case "double":
+		f, err := toFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		b.EncodeFixed64(math.Float64bits(f))
+
+	case "string":
+		switch x := v.(type) {
+		case string:
+			b.EncodeStringBytes(x)
+		case InvalidUTF8:
+			b.EncodeStringBytes(string(x))
+		case map[string]interface{}:
+			// {"invalidUtf8": base64} as marshaled by InvalidUTF8
+			s, ok := x["invalidUtf8"].(string)
+			if !ok || len(x) != 1 {
+				return fmt.Errorf("expected string, got %s", jsonType(v))
+			}
+			data, err := decodeBase64(s)
+			if err != nil {
+				return err
+			}
+			b.EncodeStringBytes(string(data))
+		default:
+			return fmt.Errorf("expected string, got %s", jsonType(v))
+		}
+
+	case "bytes":
+		switch x := v.(type) {
+		case []byte:
+			b.EncodeRawBytes(x)
+		case string:
+			data, err := decodeBase64(x)
+			if err != nil {
+				return err
+			}
+			b.EncodeRawBytes(data)
+		default:
+			return fmt.Errorf("expected base64 string, got %s", jsonType(v))
+		}
+	}
+	return nil
+}
+
+// messageFields returns the fields of m, those of its oneofs included, by
+// field number
+func messageFields(m *pp.Message) []*fieldInfo {
+	proto3 := isProto3(m)
+	var fields []*fieldInfo
+
+	add := func(f *pp.Field, repeated bool, keyType string) {
+		fi := &fieldInfo{
+			name:     f.Name,
+			jsonName: jsonName(f.Name),
+			typ:      f.Type,
+			keyType:  keyType,
+			number:   f.Sequence,
+			repeated: repeated,
+			packed:   proto3,
+		}
+		for _, o := range f.Options {
+			switch o.Name {
+			case "json_name":
+				fi.jsonName = o.Constant.Source
+			case "packed":
+				fi.packed = o.Constant.Source == "true"
+			}
+		}
+		fields = append(fields, fi)
+	}
+
+	var walk func(elements []pp.Visitee)
+	walk = func(elements []pp.Visitee) {
+		for _, each := range elements {
+			switch f := each.(type) {
+			case *pp.NormalField:
+				add(f.Field, f.Repeated, "")
+			case *pp.MapField:
+				add(f.Field, false, f.KeyType)
+			case *pp.OneOfField:
+				add(f.Field, false, "")
+			case *pp.Oneof:
+				walk(f.Elements)
+			}
+		}
+	}
+	walk(m.Elements)
+
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].number < fields[j].number })
+	return fields
+}
+
+// isProto3 reports whether m is declared in a proto3 file
+func isProto3(m *pp.Message) bool {
+	var v pp.Visitee = m
+	for v != nil {
+		switch x := v.(type) {
+		case *pp.Proto:
+			for _, each := range x.Elements {
+				if s, ok := each.(*pp.Syntax); ok {
+					return s.Value == "proto3"
+				}
+			}
+			return false
+		case *pp.Message:
+			v = x.Parent
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// jsonName returns the lowerCamelCase JSON name of the field name
+func jsonName(name string) string {
+	var (
+		b     strings.Builder
+		upper bool
+	)
+	for _, r := range name {
+		if r == '_' {
+			upper = true
+			continue
+		}
+		if upper && 'a' <= r && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		upper = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// jsonType names the JSON type of v for errors
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "bool"
+	case []byte:
+		return "bytes"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// numberText returns the text of v if it is a number or a string which
+// protojson allows for numbers
+func numberText(v interface{}, what string) (string, error) {
+	switch x := v.(type) {
+	case json.Number:
+		return string(x), nil
+	case string:
+		return x, nil
+	}
+	return "", fmt.Errorf("expected %s, got %s", what, jsonType(v))
+}
+
+func toInt(v interface{}, bits int) (int64, error) {
+	s, err := numberText(v, "integer")
+	if err != nil {
+		return 0, err
+	}
+	// unlike the text format, JSON takes decimal integers only
+	n, err := strconv.ParseInt(s, 10, bits)
+	if err == nil {
+		return n, nil
+	}
+	// integral numbers in exponent notation like 1e3 are fine too
+	if f, ferr := parseDecimalFloat(s); ferr == nil && f == math.Trunc(f) {
+		if n, err := strconv.ParseInt(strconv.FormatFloat(f, 'f', -1, 64), 10, bits); err == nil {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid int%d %s", bits, s)
+}
+
+func toUint(v interface{}, bits int) (uint64, error) {
+	s, err := numberText(v, "integer")
+	if err != nil {
+		return 0, err
+	}
+	n, err := strconv.ParseUint(s, 10, bits)
+	if err == nil {
+		return n, nil
+	}
+	if f, ferr := parseDecimalFloat(s); ferr == nil && f == math.Trunc(f) {
+		if n, err := strconv.ParseUint(strconv.FormatFloat(f, 'f', -1, 64), 10, bits); err == nil {
+			return n, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid uint%d %s", bits, s)
+}
+
+// parseDecimalFloat parses s like strconv.ParseFloat, but without the
+// underscores, hex and the infinities which it takes as well
+func parseDecimalFloat(s string) (float64, error) {
+	if strings.Trim(s, "0123456789.eE+-") != "" {
+		return 0, fmt.Errorf("invalid decimal %s", s)
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func toFloat(v interface{}, bits int) (float64, error) {
+	s, err := numberText(v, "number")
+	if err != nil {
+		return 0, err
+	}
+	switch s {
+	case "NaN":
+		return math.NaN(), nil
+	case "Infinity":
+		return math.Inf(1), nil
+	case "-Infinity":
+		return math.Inf(-1), nil
+	}
+	f, err := strconv.ParseFloat(s, bits)
+	if err != nil {
+		return 0, fmt.Errorf("invalid float%d %s", bits, s)
+	}
+	return f, nil
+}
+
+// decodeBase64 decodes standard or URL safe base64 with or without padding
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	enc := base64.RawStdEncoding
+	if strings.ContainsAny(s, "-_") {
+		enc = base64.RawURLEncoding
+	}
+	data, err := enc.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 %q", s)
+	}
+	return data, nil
+}
+
+// enumValue returns the number of the enum value v, a name or a number
+func enumValue(e *pp.Enum, v interface{}) (int32, error) {
+	if name, ok := v.(string); ok {
+		for _, each := range e.Elements {
+			if ef, ok := each.(*pp.EnumField); ok && ef.Name == name {
+				return int32(ef.Integer), nil
+			}
+		}
+		return 0, fmt.Errorf("unknown value %s of enum %s", name, e.Name)
+	}
+	n, err := toInt(v, 32)
+	if err != nil {
+		return 0, fmt.Errorf("expected enum %s, got %s", e.Name, jsonType(v))
+	}
+	return int32(n), nil
+}
+
+// mapKey converts the JSON object key k to a value of the map key type
+func mapKey(keyType, k string) (interface{}, error) {
+	switch keyType {
+	case "string":
+		// keys which are not valid UTF-8 are decoded like "\xfe" (invalid utf-8)
+		if q := strings.TrimSuffix(k, invalidUTF8Key); q != k {
+			if s, err := strconv.Unquote(q); err == nil && !utf8.ValidString(s) {
+				return InvalidUTF8(s), nil
+			}
+		}
+		return k, nil
+	case "bool":
+		b, err := strconv.ParseBool(k)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bool key %s", k)
+		}
+		return b, nil
+	}
+	return json.Number(k), nil
+}
@@ -0,0 +1,125 @@
+package inspector
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	testv1 "github.com/detailyang/pb-inspector-go/proto/go/proto/test/v1"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeJSON(t *testing.T) {
+	in := NewInspector()
+	require.Nil(t, in.ReadSchemaFromFile("../proto/test/v1/test.proto"))
+
+	raw, err := in.EncodeJSON("test.v1", "Test", []byte(`{
+  "int32": -1,
+  "int64": "2",
+  "float": 3,
+  "double": "Infinity",
+  "uint32": 5,
+  "uint64": 6e0,
+  "bool": true,
+  "bytes": "aGFoYQ",
+  "string": "hello world",
+  "test2": {"name": "bob"}
+}`))
+	require.Nil(t, err)
+
+	test := testv1.Test{
+		Int32:   -1,
+		Int64:   2,
+		Float:   3.0,
+		Double:  math.Inf(1),
+		Uint32:  5,
+		Uint64:  6,
+		Bool:    true,
+		Bytes:   []byte("haha"),
+		String_: "hello world",
+		Test2:   &testv1.Test2{Name: "bob"},
+	}
+	expected, err := proto.Marshal(&test)
+	require.Nil(t, err)
+	require.Equal(t, expected, raw)
+
+	tests := []struct {
+		json string
+		err  string
+	}{
+		{`{"int32": "x"}`, "inspector: int32: invalid int32 x"},
+		{`{"int32": 2147483648}`, "inspector: int32: invalid int32 2147483648"},
+		{`{"int64": "0x10"}`, "inspector: int64: invalid int64 0x10"},
+		{`{"uint32": "1_0"}`, "inspector: uint32: invalid uint32 1_0"},
+		{`{"test2": {"nam": "bob"}}`, "inspector: test2.nam: unknown field of Test2"},
+		{`{"bool": 1}`, "inspector: bool: expected bool, got number"},
+		{`[]`, "inspector: expected object, got array"},
+	}
+	for _, test := range tests {
+		_, err := in.EncodeJSON("test.v1", "Test", []byte(test.json))
+		require.NotNil(t, err, test.json)
+		require.Equal(t, test.err, err.Error())
+	}
+
+	// integers in strings are decimal, not octal
+	raw, err = in.EncodeJSON("test.v1", "Test", []byte(`{"int32": "010"}`))
+	require.Nil(t, err)
+	require.Equal(t, []byte{0x08, 0x0a}, raw)
+}
+
+func TestEncodeJSONCollections(t *testing.T) {
+	schema := `
+syntax = "proto3";
+
+package envelope.v1;
+
+enum Kind {
+  UNKNOWN = 0;
+  ITEM = 1;
+}
+
+message Envelope {
+  repeated int32 ids = 1;
+  repeated Item items = 2;
+  map<string, int64> attrs = 3;
+  Kind kind = 4;
+  oneof body {
+    string text = 5;
+    sint32 delta = 6;
+  }
+  repeated string tags = 7 [json_name = "labels"];
+}
+
+message Item {
+  int32 item_id = 1;
+}
+`
+	in := NewInspector()
+	require.Nil(t, in.ReadSchemaFromReader("envelope.proto", strings.NewReader(schema)))
+
+	raw, err := in.EncodeJSON("envelope.v1", "Envelope", []byte(`{
+  "ids": [1, 150],
+  "items": [{"itemId": 1}, {"item_id": 2}],
+  "attrs": {"b": "2", "a": 1},
+  "kind": "ITEM",
+  "delta": -2,
+  "labels": ["x"]
+}`))
+	require.Nil(t, err)
+	require.Equal(t, []byte{
+		0x0a, 0x03, 0x01, 0x96, 0x01, // ids
+		0x12, 0x02, 0x08, 0x01, // items
+		0x12, 0x02, 0x08, 0x02, // items
+		0x1a, 0x05, 0x0a, 0x01, 0x61, 0x10, 0x01, // attrs a
+		0x1a, 0x05, 0x0a, 0x01, 0x62, 0x10, 0x02, // attrs b
+		0x20, 0x01, // kind
+		0x30, 0x03, // delta
+		0x3a, 0x01, 0x78, // tags
+	}, raw)
+
+	_, err = in.EncodeJSON("envelope.v1", "Envelope", []byte(`{"items": [{"itemId": "x"}]}`))
+	require.Equal(t, "inspector: items[0].item_id: invalid int32 x", err.Error())
+	_, err = in.EncodeJSON("envelope.v1", "Envelope", []byte(`{"kind": "OTHER"}`))
+	require.Equal(t, "inspector: kind: unknown value OTHER of enum Kind", err.Error())
+}
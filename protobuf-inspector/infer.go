@@ -0,0 +1,286 @@
+package inspector
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+// messageShape aggregates the fields of one message type observed by
+// DecodeWithoutSchema
+type messageShape struct {
+	fields map[uint64]*fieldShape
+}
+
+// fieldShape aggregates the observations of one field of a message type
+type fieldShape struct {
+	wires     map[uint64]bool
+	repeated  bool
+	varints   []uint64
+	fixed32s  []uint64
+	fixed64s  []uint64
+	payloads  [][]byte
+	nested    *messageShape
+	isMessage bool
+	isText    bool
+	isPacked  bool
+}
+
+func newMessageShape() *messageShape {
+	return &messageShape{fields: map[uint64]*fieldShape{}}
+}
+
+func (m *messageShape) field(tag uint64) *fieldShape {
+	f, ok := m.fields[tag]
+	if !ok {
+		f = &fieldShape{
+			wires:     map[uint64]bool{},
+			isMessage: true,
+			isText:    true,
+			isPacked:  true,
+		}
+		m.fields[tag] = f
+	}
+	return f
+}
+
+// nestedShape returns the shape of the embedded message or group of the field.
+func (f *fieldShape) nestedShape() *messageShape {
+	if f.nested == nil {
+		f.nested = newMessageShape()
+	}
+	return f.nested
+}
+
+// observe adds the fields of one message to the shape.
+func (m *messageShape) observe(fields []*RawField) {
+	counts := map[uint64]int{}
+
+	for _, rf := range fields {
+		if rf.Kind == KindEndGroup {
+			continue
+		}
+
+		f := m.field(rf.Tag)
+		f.wires[rf.Wire] = true
+		counts[rf.Tag]++
+		if counts[rf.Tag] > 1 {
+			f.repeated = true
+		}
+
+		switch rf.Kind {
+		case KindVarint:
+			f.varints = append(f.varints, rf.Value)
+
+		case KindFixed32:
+			f.fixed32s = append(f.fixed32s, rf.Value)
+
+		case KindFixed64:
+			f.fixed64s = append(f.fixed64s, rf.Value)
+
+		case KindMessage, KindString, KindBytes:
+			f.payloads = append(f.payloads, rf.Raw)
+			f.isMessage = f.isMessage && rf.Kind == KindMessage
+			f.isText = f.isText && isText(rf.Raw)
+			_, packed := packedVarints(rf.Raw)
+			f.isPacked = f.isPacked && packed
+			if rf.Kind == KindMessage {
+				f.nestedShape().observe(rf.Children)
+			}
+
+		case KindStartGroup:
+			f.nestedShape().observe(rf.Children)
+		}
+	}
+}
+
+// group reports whether the field is always encoded as a group.
+func (f *fieldShape) group() bool {
+	return len(f.wires) == 1 && f.wires[proto.WireStartGroup]
+}
+
+// embedded reports whether the field looks like an embedded message. Text
+// which happens to parse as a message is much more common than messages
+// made of printable bytes only, so text wins.
+func (f *fieldShape) embedded() bool {
+	if f.wires[proto.WireStartGroup] {
+		return f.group()
+	}
+	return len(f.wires) == 1 && f.wires[proto.WireBytes] && f.isMessage && !f.isText
+}
+
+// typeName returns the inferred proto type of the field with number tag.
+func (f *fieldShape) typeName(tag uint64) (typ string, repeated bool, comment string) {
+	repeated = f.repeated
+
+	if len(f.wires) > 1 {
+		// A scalar field which is sometimes packed and sometimes not.
+		if len(f.wires) == 2 && f.wires[proto.WireVarint] && f.wires[proto.WireBytes] && f.isPacked {
+			vs := f.varints
+			for _, r := range f.payloads {
+				packed, _ := packedVarints(r)
+				vs = append(vs, packed...)
+			}
+			return varintType(vs), true, ""
+		}
+		return "bytes", repeated, "conflicting wire types"
+	}
+
+	switch {
+	case f.wires[proto.WireVarint]:
+		return varintType(f.varints), repeated, ""
+
+	case f.wires[proto.WireFixed32]:
+		if plausibleFloats(f.fixed32s, func(v uint64) float64 { return float64(math.Float32frombits(uint32(v))) }) {
+			return "float", repeated, ""
+		}
+		return "fixed32", repeated, ""
+
+	case f.wires[proto.WireFixed64]:
+		if plausibleFloats(f.fixed64s, math.Float64frombits) {
+			return "double", repeated, ""
+		}
+		return "fixed64", repeated, ""
+
+	case f.group():
+		return nestedName(tag), repeated, ""
+
+	case f.embedded():
+		return nestedName(tag), repeated, ""
+
+	case f.isText:
+		return "string", repeated, ""
+
+	case f.isPacked:
+		return "bytes", repeated, "may be a packed repeated varint"
+	}
+
+	return "bytes", repeated, ""
+}
+
+// varintType returns the narrowest proto type which holds all of vs.
+func varintType(vs []uint64) string {
+	var (
+		isBool  = true
+		isInt32 = true
+	)
+	for _, v := range vs {
+		if v > 1 {
+			isBool = false
+		}
+		if int64(v) < math.MinInt32 || int64(v) > math.MaxInt32 {
+			isInt32 = false
+		}
+	}
+	switch {
+	case isBool:
+		return "bool"
+	case isInt32:
+		return "int32"
+	}
+	return "int64"
+}
+
+func nestedName(tag uint64) string {
+	return fmt.Sprintf("Field%d", tag)
+}
+
+// hasGroup reports whether a field of the shape or of its embedded messages
+// is encoded as a group, which proto3 does not allow.
+func (m *messageShape) hasGroup() bool {
+	for _, f := range m.fields {
+		if f.wires[proto.WireStartGroup] {
+			return true
+		}
+		if f.nested != nil && f.embedded() && f.nested.hasGroup() {
+			return true
+		}
+	}
+	return false
+}
+
+// write writes the shape as the message name. Fields of proto2 messages
+// which are not repeated are labeled optional.
+func (m *messageShape) write(w io.Writer, name string, depth int, proto2 bool) {
+	indent := strings.Repeat("  ", depth)
+	fmt.Fprintf(w, "%smessage %s {\n", indent, name)
+	m.writeFields(w, depth, proto2)
+	fmt.Fprintf(w, "%s}\n", indent)
+}
+
+// writeFields writes the fields and the embedded messages of the shape in
+// a message or group indented by depth. Groups declare their fields in
+// place.
+func (m *messageShape) writeFields(w io.Writer, depth int, proto2 bool) {
+	indent := strings.Repeat("  ", depth)
+
+	tags := make([]uint64, 0, len(m.fields))
+	for tag := range m.fields {
+		tags = append(tags, tag)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+
+	for _, tag := range tags {
+		f := m.fields[tag]
+		typ, repeated, comment := f.typeName(tag)
+		label := ""
+		switch {
+		case repeated:
+			label = "repeated "
+		case proto2:
+			label = "optional "
+		}
+		if f.group() {
+			fmt.Fprintf(w, "%s  %sgroup %s = %d {\n", indent, label, typ, tag)
+			if f.nested != nil {
+				f.nested.writeFields(w, depth+1, proto2)
+			}
+			fmt.Fprintf(w, "%s  }\n", indent)
+			continue
+		}
+		if comment != "" {
+			comment = " // " + comment
+		}
+		fmt.Fprintf(w, "%s  %s%s field_%d = %d;%s\n", indent, label, typ, tag, tag, comment)
+	}
+	for _, tag := range tags {
+		if f := m.fields[tag]; f.nested != nil && f.embedded() && !f.group() {
+			fmt.Fprintln(w)
+			f.nested.write(w, nestedName(tag), depth+1, proto2)
+		}
+	}
+}
+
+// InferSchema guesses the proto definition of the message pkg.name from the
+// samples and writes it to w
+func InferSchema(pkg, name string, samples [][]byte, w io.Writer) error {
+	if len(samples) == 0 {
+		return fmt.Errorf("inspector: no samples to infer schema from")
+	}
+
+	shape := newMessageShape()
+	for i, raw := range samples {
+		m, err := DecodeWithoutSchema(raw)
+		if err != nil {
+			return fmt.Errorf("inspector: sample %d: %v", i, err)
+		}
+		shape.observe(m.Fields)
+	}
+
+	syntax := "proto3"
+	proto2 := shape.hasGroup()
+	if proto2 {
+		syntax = "proto2"
+	}
+	fmt.Fprintf(w, "syntax = \"%s\";\n\n", syntax)
+	if pkg != "" {
+		fmt.Fprintf(w, "package %s;\n\n", pkg)
+	}
+	fmt.Fprintf(w, "// %s was inferred from %d sample(s).\n", name, len(samples))
+	shape.write(w, name, 0, proto2)
+	return nil
+}
@@ -0,0 +1,98 @@
+package inspector
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	testv1 "github.com/detailyang/pb-inspector-go/proto/go/proto/test/v1"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInferSchema(t *testing.T) {
+	samples := []proto.Message{
+		&testv1.Test{
+			Int32:   -1,
+			Float:   3.5,
+			Bool:    true,
+			String_: "hello world",
+			Test2:   &testv1.Test2{Name: "bob"},
+		},
+		&testv1.Test{
+			Int64:  1 << 40,
+			Double: 4.0,
+			Uint32: 5,
+			Bytes:  []byte{0xff, 0x00},
+		},
+	}
+
+	var raws [][]byte
+	for _, each := range samples {
+		raw, err := proto.Marshal(each)
+		require.Nil(t, err)
+		raws = append(raws, raw)
+	}
+	// field 11 repeated
+	raws = append(raws, []byte{0x58, 0x01, 0x58, 0x02})
+
+	w := bytes.NewBuffer(nil)
+	require.Nil(t, NewInspector().InferSchema("test.v1", "Test", raws, w))
+
+	o := `syntax = "proto3";
+
+package test.v1;
+
+// Test was inferred from 3 sample(s).
+message Test {
+  int32 field_1 = 1;
+  int64 field_2 = 2;
+  float field_3 = 3;
+  double field_4 = 4;
+  int32 field_5 = 5;
+  bool field_7 = 7;
+  bytes field_8 = 8;
+  string field_9 = 9;
+  Field10 field_10 = 10;
+  repeated int32 field_11 = 11;
+
+  message Field10 {
+    string field_1 = 1;
+  }
+}
+`
+	require.Equal(t, o, w.String())
+}
+
+func TestInferSchemaWithGroup(t *testing.T) {
+	// field 1 varint 1, field 2 group of field 3 string "hi"
+	raw := []byte{0x08, 0x01, 0x13, 0x1a, 0x02, 0x68, 0x69, 0x14}
+
+	w := bytes.NewBuffer(nil)
+	require.Nil(t, NewInspector().InferSchema("", "Test", [][]byte{raw}, w))
+
+	o := `syntax = "proto2";
+
+// Test was inferred from 1 sample(s).
+message Test {
+  optional bool field_1 = 1;
+  optional group Field2 = 2 {
+    optional string field_3 = 3;
+  }
+}
+`
+	require.Equal(t, o, w.String())
+
+	// the inferred schema decodes its sample
+	in := NewInspector()
+	require.Nil(t, in.ReadSchemaFromReader("test.proto", strings.NewReader(w.String())))
+	m, err := in.ToMapWithSchema("", "Test", raw)
+	require.Nil(t, err)
+	require.Equal(t, map[string]interface{}{
+		"field_1": true,
+		"field2":  map[string]interface{}{"field_3": "hi"},
+	}, m)
+	issues, err := in.Validate("", "Test", raw)
+	require.Nil(t, err)
+	require.Empty(t, issues)
+}
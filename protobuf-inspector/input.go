@@ -0,0 +1,241 @@
+package inspector
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DecodeInput decodes the content of a file which is encoded as filetype,
+// one of binary, hex, escaped, base64, base64url or auto to detect it by
+// DetectFileType
+func DecodeInput(raw []byte, filetype string) ([]byte, error) {
+	switch filetype {
+	case "binary":
+		return raw, nil
+	case "hex":
+		return decodeHex(string(raw))
+	case "escaped":
+		return decodeEscaped(string(raw))
+	case "base64":
+		return decodeBase64Input(raw, base64.RawStdEncoding)
+	case "base64url":
+		return decodeBase64Input(raw, base64.RawURLEncoding)
+	case "auto":
+		detected := DetectFileType(raw)
+		if detected == "" {
+			return nil, fmt.Errorf("inspector: cannot detect the file type of blank input")
+		}
+		return DecodeInput(raw, detected)
+	}
+	return nil, fmt.Errorf("inspector: unknown file type %s", filetype)
+}
+
+// DetectFileType guesses whether raw is hex, base64, base64url, an escaped
+// string or binary. Hex wins over base64 since every hex string is valid
+// base64 too. It returns "" for input which is blank but not empty, which
+// is as likely a text file without content as a binary message.
+func DetectFileType(raw []byte) string {
+	s := strings.Join(strings.Fields(string(raw)), "")
+	if len(raw) == 0 {
+		return "binary"
+	}
+	if s == "" {
+		return ""
+	}
+
+	if len(s)%2 == 0 && isHex(s) {
+		return "hex"
+	}
+
+	body := strings.TrimRight(s, "=")
+	if len(s)-len(body) <= 2 {
+		if isBase64(body, "+/") {
+			if _, err := decodeBase64Input(raw, base64.RawStdEncoding); err == nil {
+				return "base64"
+			}
+		}
+		if isBase64(body, "-_") {
+			if _, err := decodeBase64Input(raw, base64.RawURLEncoding); err == nil {
+				return "base64url"
+			}
+		}
+	}
+
+	if !utf8.Valid(raw) {
+		return "binary"
+	}
+	if strings.Contains(s, "\\") {
+		if _, err := decodeEscaped(string(raw)); err == nil {
+			return "escaped"
+		}
+	}
+	if _, err := decodeHex(string(raw)); err == nil {
+		return "hex"
+	}
+
+	return "binary"
+}
+
+// decodeBase64Input decodes base64 of enc with or without padding and line
+// breaks
+func decodeBase64Input(raw []byte, enc *base64.Encoding) ([]byte, error) {
+	s := strings.Join(strings.Fields(string(raw)), "")
+	return enc.DecodeString(strings.TrimRight(s, "="))
+}
+
+// isBase64 reports whether s only has the base64 letters and digits and the
+// extra two characters of its alphabet.
+func isBase64(s string, extra string) bool {
+	return strings.IndexFunc(s, func(r rune) bool {
+		return !('A' <= r && r <= 'Z' || 'a' <= r && r <= 'z' || '0' <= r && r <= '9' || strings.ContainsRune(extra, r))
+	}) < 0
+}
+
+// decodeHex decodes hex dumped by xxd, hexdump -C or Wireshark, C arrays
+// like "0x0a, 0x05" and plain hex with any spaces and line breaks.
+func decodeHex(s string) ([]byte, error) {
+	// C arrays keep the bytes in braces
+	if i, j := strings.Index(s, "{"), strings.LastIndex(s, "}"); i >= 0 && j > i {
+		s = s[i+1 : j]
+	}
+
+	var (
+		out  []byte
+		dump bool
+	)
+	for _, line := range strings.Split(s, "\n") {
+		b, err := decodeHexLine(line, &dump)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, b...)
+	}
+	return out, nil
+}
+
+// decodeHexLine decodes one line of decodeHex. dump tells whether the lines
+// so far start with offsets.
+func decodeHexLine(line string, dump *bool) ([]byte, error) {
+	// hexdump -C writes the text column in |...|
+	if i := strings.Index(line, "|"); i >= 0 {
+		line = line[:i]
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	// the last line of a dump may be the total size alone
+	if *dump && len(fields) == 1 && isHex(strings.TrimSuffix(fields[0], ":")) {
+		return nil, nil
+	}
+
+	// xxd writes "offset: 0a05 6865  ..he" and the text column follows
+	// the hex after two spaces
+	if offset := fields[0]; len(offset) > 1 && strings.HasSuffix(offset, ":") && isHex(offset[:len(offset)-1]) {
+		*dump = true
+		line = strings.TrimLeft(line, " \t")[len(offset):]
+		line = strings.TrimLeft(line, " ")
+		if i := strings.Index(line, "  "); i >= 0 {
+			line = line[:i]
+		}
+		return hex.DecodeString(strings.Join(strings.Fields(line), ""))
+	}
+
+	// hexdump -C and Wireshark write "offset  0a 05 68 65  ..he" with up to
+	// 16 bytes a line, single spaces between them but two after the eighth,
+	// and the text column after a wider gap
+	if offset := fields[0]; len(offset) >= 4 && isHex(offset) && len(fields) > 1 && len(fields[1]) == 2 &&
+		strings.Contains(line, offset+"  ") {
+		*dump = true
+		return decodeDumpBytes(line[strings.Index(line, offset)+len(offset):]), nil
+	}
+
+	// plain hex, C arrays and escaped hex like "0x0a, 0x05" or "\x0a\x05"
+	var out []byte
+	separators := func(r rune) bool {
+		return unicode.IsSpace(r) || strings.ContainsRune(",;{}()[]", r)
+	}
+	for _, f := range strings.FieldsFunc(line, separators) {
+		for _, token := range splitHexPrefix(f) {
+			b, err := hex.DecodeString(token)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, b...)
+		}
+	}
+	return out, nil
+}
+
+// decodeDumpBytes decodes the hex column which starts s, a line of a dump
+// after its offset, up to the gap before the text column.
+func decodeDumpBytes(s string) []byte {
+	var out []byte
+	for len(out) < 16 {
+		rest := strings.TrimLeft(s, " ")
+		gap := len(s) - len(rest)
+		if out != nil && gap != 1 && !(gap == 2 && len(out) == 8) {
+			break
+		}
+		if len(rest) < 2 || !isHex(rest[:2]) || len(rest) > 2 && rest[2] != ' ' {
+			break
+		}
+		b, _ := hex.DecodeString(rest[:2])
+		out = append(out, b...)
+		s = rest[2:]
+	}
+	return out
+}
+
+// splitHexPrefix splits f at the 0x and \x prefixes of the bytes in it and
+// pads the bytes which are a single digit like 0x5.
+func splitHexPrefix(f string) []string {
+	f = strings.NewReplacer("0x", " ", "0X", " ", "\\x", " ").Replace(f)
+	if !strings.Contains(f, " ") {
+		return []string{f}
+	}
+	tokens := strings.Fields(f)
+	for i, token := range tokens {
+		if len(token) == 1 {
+			tokens[i] = "0" + token
+		}
+	}
+	return tokens
+}
+
+func isHex(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789abcdefABCDEF") == ""
+}
+
+// decodeEscaped decodes a C or Go escaped string like "\n\x05hello" or
+// "\n\005hello", with or without the quotes.
+func decodeEscaped(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' && s[len(s)-1] == '"' || s[0] == '\'' && s[len(s)-1] == '\'') {
+		s = s[1 : len(s)-1]
+	}
+
+	var out []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			out = append(out, s[i])
+			continue
+		}
+		if i+1 == len(s) {
+			return nil, fmt.Errorf("inspector: escaped string ends with a backslash")
+		}
+		b, n, err := unescape(s[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("inspector: escaped string at %d: %v", i, err)
+		}
+		out = append(out, b...)
+		i += n
+	}
+	return out, nil
+}
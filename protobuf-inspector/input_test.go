@@ -0,0 +1,78 @@
+package inspector
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeInput(t *testing.T) {
+	raw := []byte{0x0a, 0x03, 0x62, 0x6f, 0x62}
+
+	tests := []struct {
+		in       string
+		filetype string
+		detected string
+	}{
+		{"0a03626f62", "hex", "hex"},
+		{"0a 03 62\n6f 62\n", "hex", "hex"},
+		{"00000000: 0a03 626f 62                   ..bob\n", "hex", "hex"},
+		{"00000000  0a 03 62 6f 62                    |..bob|\n00000005\n", "hex", "hex"},
+		{"0000  0a 03 62 6f 62                    ..bob\n", "hex", "hex"},
+		{"0000   0a 03 62 6f 62   ..bob\n", "hex", "hex"},
+		{"unsigned char raw[] = { 0x0a, 0x3, 0x62, 0x6f, 0x62 };", "hex", "hex"},
+		{`\x0a\x03\x62\x6f\x62`, "hex", "escaped"},
+		{"CgNib2I=", "base64", "base64"},
+		{"CgNib2I", "base64", "base64"},
+		{"CgNib2I=\n", "base64", "base64"},
+		{`"\n\x03bob"`, "escaped", "escaped"},
+		{`\n\003bob`, "escaped", "escaped"},
+		{`'\n\u0003bob'`, "escaped", "escaped"},
+		{string(raw), "binary", "binary"},
+	}
+	for _, test := range tests {
+		b, err := DecodeInput([]byte(test.in), test.filetype)
+		require.Nil(t, err, test.in)
+		require.Equal(t, raw, b, test.in)
+
+		require.Equal(t, test.detected, DetectFileType([]byte(test.in)), test.in)
+		b, err = DecodeInput([]byte(test.in), "auto")
+		require.Nil(t, err, test.in)
+		require.Equal(t, raw, b, test.in)
+	}
+
+	// base64url differs from base64 in - and _
+	b, err := DecodeInput([]byte("-_8"), "auto")
+	require.Nil(t, err)
+	require.Equal(t, []byte{0xfb, 0xff}, b)
+	require.Equal(t, "base64url", DetectFileType([]byte("-_8")))
+
+	// the text column of a short last line is no hex
+	b, err = DecodeInput([]byte("0000   61 62 63 64 65 66 67 68  69 6a 6b 6c 6d 6e 6f 70   abcdefghijklmnop\n0010   61 62   ab\n"), "hex")
+	require.Nil(t, err)
+	require.Equal(t, []byte("abcdefghijklmnopab"), b)
+	b, err = DecodeInput([]byte("00000000  61 62 63 64 65 66 67 68  69 6a 6b 6c 6d 6e 6f 70  |abcdefghijklmnop|\n00000010  61 62                                             |ab|\n00000012\n"), "hex")
+	require.Nil(t, err)
+	require.Equal(t, []byte("abcdefghijklmnopab"), b)
+
+	require.Equal(t, "binary", DetectFileType(nil))
+	require.Equal(t, "", DetectFileType([]byte(" \n")))
+	_, err = DecodeInput([]byte(" \n"), "auto")
+	require.Equal(t, "inspector: cannot detect the file type of blank input", err.Error())
+
+	errors := []struct {
+		in       string
+		filetype string
+		err      string
+	}{
+		{`\q`, "escaped", `inspector: escaped string at 0: invalid escape \q`},
+		{`ab\`, "escaped", "inspector: escaped string ends with a backslash"},
+		{`\400`, "escaped", `inspector: escaped string at 0: invalid escape \400`},
+		{"0a03", "lz4", "inspector: unknown file type lz4"},
+	}
+	for _, test := range errors {
+		_, err := DecodeInput([]byte(test.in), test.filetype)
+		require.NotNil(t, err, test.in)
+		require.Equal(t, test.err, err.Error())
+	}
+}
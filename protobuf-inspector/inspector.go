@@ -1,13 +1,17 @@
 package inspector
 
 import (
+	"fmt"
 	"io"
 )
 
 // Inspector represents the protobuf inspector
 type Inspector struct {
-	decoder    *Decoder
-	definition *Definition
+	decoder     *Decoder
+	definition  *Definition
+	decodeBytes bool
+	recover     bool
+	disassemble bool
 }
 
 // NewInspector returns the inspector to inpsect protobuf
@@ -17,6 +21,24 @@ func NewInspector() *Inspector {
 	}
 }
 
+// SetDecodeBytes sets whether bytes fields which look like embedded messages
+// are decoded without schema
+func (p *Inspector) SetDecodeBytes(decodeBytes bool) {
+	p.decodeBytes = decodeBytes
+}
+
+// SetRecover sets whether decoding without schema resynchronizes after
+// malformed fields instead of stopping at the first one
+func (p *Inspector) SetRecover(recover bool) {
+	p.recover = recover
+}
+
+// SetDisassemble sets whether inspecting without schema writes the
+// protoscope-like language of Disassemble instead of the annotated fields
+func (p *Inspector) SetDisassemble(disassemble bool) {
+	p.disassemble = disassemble
+}
+
 // ReadSchemaFromReader reads schema from reader which named f.
 func (p *Inspector) ReadSchemaFromReader(f string, r io.Reader) error {
 	return p.definition.ReadFrom(f, r)
@@ -27,17 +49,177 @@ func (p *Inspector) ReadSchemaFromFile(f string) error {
 	return p.definition.ReadFile(f)
 }
 
+// MethodMessage returns the package and name of the request or response
+// message of method, which is named like pkg.Service/Method
+func (p *Inspector) MethodMessage(method string, response bool) (pkg, name string, err error) {
+	return p.definition.MethodMessage(method, response)
+}
+
 // ToMapWithSchema maps raw bytes to map[string]interface{} by self definition
 func (p *Inspector) ToMapWithSchema(pkg, name string, raw []byte) (map[string]interface{}, error) {
-	return NewDecoder(p.definition, NewBuffer(raw)).Decode(pkg, name)
+	return p.newDecoder(p.definition, raw).Decode(pkg, name)
 }
 
 // ToMapWithSchema maps raw bytes to map[string]interface{} by specified definition
 func (p *Inspector) ToMapWithSchemaByDefinition(d *Definition, pkg, name string, raw []byte) (map[string]interface{}, error) {
-    return NewDecoder(d, NewBuffer(raw)).Decode(pkg, name)
+    return p.newDecoder(d, raw).Decode(pkg, name)
+}
+
+// EncodeJSON encodes the JSON object data to protobuf binary data as the
+// message pkg.name of self definition
+func (p *Inspector) EncodeJSON(pkg, name string, data []byte) ([]byte, error) {
+	return NewEncoder(p.definition).EncodeJSON(pkg, name, data)
+}
+
+// EncodeText encodes data in protobuf text format to protobuf binary data
+// as the message pkg.name of self definition
+func (p *Inspector) EncodeText(pkg, name string, data []byte) ([]byte, error) {
+	return NewEncoder(p.definition).EncodeText(pkg, name, data)
+}
+
+// Patch applies edits like test2.name="bob", int32=7 or delete bytes to
+// raw, the message pkg.name of self definition, keeping the bytes of the
+// fields they do not change
+func (p *Inspector) Patch(pkg, name string, raw []byte, edits []string) ([]byte, error) {
+	parsed := make([]Edit, 0, len(edits))
+	for _, each := range edits {
+		edit, err := ParseEdit(each)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, edit)
+	}
+	return NewEncoder(p.definition).Patch(pkg, name, raw, parsed)
+}
+
+// DiffWithSchema decodes a and b, messages pkg.name of self definition,
+// and returns their differences by field path
+func (p *Inspector) DiffWithSchema(pkg, name string, a, b []byte) ([]Change, error) {
+	ma, err := p.ToMapWithSchema(pkg, name, a)
+	if err != nil {
+		return nil, err
+	}
+	mb, err := p.ToMapWithSchema(pkg, name, b)
+	if err != nil {
+		return nil, err
+	}
+	return DiffWithDefinition(p.definition, pkg, name, ma, mb)
+}
+
+// DiffWithoutSchema decodes a and b without schema and returns their
+// differences by tag number paths
+func (p *Inspector) DiffWithoutSchema(a, b []byte) ([]Change, error) {
+	ma, err := p.DecodeWithoutSchema(a)
+	if err != nil {
+		return nil, err
+	}
+	mb, err := p.DecodeWithoutSchema(b)
+	if err != nil {
+		return nil, err
+	}
+	return Diff(ma.ToMap(), mb.ToMap()), nil
+}
+
+// Validate decodes raw strictly as the message pkg.name of self definition
+// and returns every problem it finds
+func (p *Inspector) Validate(pkg, name string, raw []byte) ([]Issue, error) {
+	return Validate(p.definition, pkg, name, raw)
+}
+
+// SelectWithSchema decodes raw, the message pkg.name of self definition,
+// once and returns its values at every path, like test2.name or items[*].id
+func (p *Inspector) SelectWithSchema(pkg, name string, raw []byte, paths ...string) ([]Selection, error) {
+	m, err := p.ToMapWithSchema(pkg, name, raw)
+	if err != nil {
+		return nil, err
+	}
+	var selections []Selection
+	for _, path := range paths {
+		s, err := SelectWithDefinition(p.definition, pkg, name, m, path)
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, s...)
+	}
+	return selections, nil
+}
+
+// SelectWithoutSchema decodes raw without schema once and returns its
+// values at every path, which is made of tag numbers like 10.1
+func (p *Inspector) SelectWithoutSchema(raw []byte, paths ...string) ([]Selection, error) {
+	rm, err := p.DecodeWithoutSchema(raw)
+	if err != nil {
+		return nil, err
+	}
+	m := rm.ToMap()
+	var selections []Selection
+	for _, path := range paths {
+		s, err := Select(m, path)
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, s...)
+	}
+	return selections, nil
+}
+
+// ReportWithSchema decodes raw bytes by self definition and writes a
+// standalone HTML report of the decoded fields to w
+func (p *Inspector) ReportWithSchema(pkg, name string, raw []byte, w io.Writer) error {
+	d := p.newDecoder(p.definition, raw)
+	if _, err := d.Decode(pkg, name); err != nil {
+		return err
+	}
+	title := fmt.Sprintf("%s.%s", pkg, name)
+	return WriteHTMLReport(w, title, raw, ReportNodesFromSpans(d.Spans()))
+}
+
+// InferSchema guesses the proto definition of the message pkg.name from
+// the raw samples and writes it to w
+func (p *Inspector) InferSchema(pkg, name string, samples [][]byte, w io.Writer) error {
+	return InferSchema(pkg, name, samples, w)
+}
+
+// DecodeWithoutSchema decodes raw protobuf binary data to a tree of fields
+// without schema
+func (p *Inspector) DecodeWithoutSchema(raw []byte) (*RawMessage, error) {
+	if p.recover {
+		return RecoverWithoutSchema(raw), nil
+	}
+	return DecodeWithoutSchema(raw)
+}
+
+// ReportWithoutSchema decodes raw protobuf binary data without schema and
+// writes a standalone HTML report of the decoded fields to w
+func (p *Inspector) ReportWithoutSchema(raw []byte, w io.Writer) error {
+	m, err := p.DecodeWithoutSchema(raw)
+	if err != nil {
+		return err
+	}
+	return WriteHTMLReport(w, "raw message", raw, ReportNodesFromRawFields(m.Fields))
+}
+
+// Assemble assembles src in the protoscope-like language of Disassemble to
+// protobuf binary data
+func (p *Inspector) Assemble(src []byte) ([]byte, error) {
+	return Assemble(string(src))
 }
 
 // InspectWithoutSchema inspects raw protobuf binary data and write to w
 func (p *Inspector) InspectWithoutSchema(verbose bool, raw []byte, w io.Writer) error {
+	if p.disassemble {
+		_, err := io.WriteString(w, Disassemble(raw))
+		return err
+	}
+	if p.recover {
+		RecoverWithoutSchema(raw).WriteText(w, verbose)
+		return nil
+	}
 	return NewBuffer(raw).InspectWithoutSchema(verbose, raw, w)
 }
+
+func (p *Inspector) newDecoder(d *Definition, raw []byte) *Decoder {
+	decoder := NewDecoder(d, NewBuffer(raw))
+	decoder.decodeBytes = p.decodeBytes
+	return decoder
+}
@@ -2,6 +2,10 @@ package inspector
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
 	"testing"
 
 	testv1 "github.com/detailyang/pb-inspector-go/proto/go/proto/test/v1"
@@ -31,15 +35,349 @@ func TestInspectWithoutSchema(t *testing.T) {
 	require.Nil(t, err)
 
 	o := ""
-	o += `  0: t=  1 varint 1` + "\n"
-	o += "  2: t=  2 varint 2" + "\n"
-	o += "  4: t=  3 fix32 1077936128" + "\n"
-	o += "  9: t=  4 fix64 4616189618054758400" + "\n"
-	o += " 18: t=  5 varint 5" + "\n"
-	o += " 20: t=  6 varint 6" + "\n"
-	o += " 22: t=  7 varint 1" + "\n"
-	o += " 24: t=  8 bytes [4] 68 61 68 61" + "\n"
-	o += " 30: t=  9 bytes [11] 68 65 6c .. 72 6c 64" + "\n"
+	o += `  0: t=  1 varint 1 (int64=1 sint64=-1 bool=true)` + "\n"
+	o += "  2: t=  2 varint 2 (int64=2 sint64=1)" + "\n"
+	o += "  4: t=  3 fix32 1077936128 (float=3 int32=1077936128)" + "\n"
+	o += "  9: t=  4 fix64 4616189618054758400 (double=4 int64=4616189618054758400)" + "\n"
+	o += " 18: t=  5 varint 5 (int64=5 sint64=-3)" + "\n"
+	o += " 20: t=  6 varint 6 (int64=6 sint64=3)" + "\n"
+	o += " 22: t=  7 varint 1 (int64=1 sint64=-1 bool=true)" + "\n"
+	o += ` 24: t=  8 message [4] (string="haha" packed varint=[104 97 104 97] packed float=[2.6791647e+20])` + "\n"
+	o += "   26: t= 13 varint 97 (int64=97 sint64=-49)" + "\n"
+	o += "   28: t= 13 varint 97 (int64=97 sint64=-49)" + "\n"
+	o += ` 30: t=  9 string [11] "hello world" (packed varint=[104 101 108 108 111 32 119 111 ..])` + "\n"
 
 	require.Equal(t, o, w.String())
 }
+
+func TestInspectWithoutSchemaCandidates(t *testing.T) {
+	test := testv1.Test{
+		Int64:  -2,
+		Float:  -1.5,
+		Double: 0.1,
+	}
+
+	raw, err := proto.Marshal(&test)
+	require.Nil(t, err)
+
+	w := bytes.NewBuffer(nil)
+	err = NewInspector().InspectWithoutSchema(false, raw, w)
+	require.Nil(t, err)
+
+	o := ""
+	o += "  0: t=  2 varint 18446744073709551614 (int64=-2 sint64=9223372036854775807)" + "\n"
+	o += " 11: t=  3 fix32 3217031168 (float=-1.5 int32=-1077936128)" + "\n"
+	o += " 16: t=  4 fix64 4591870180066957722 (double=0.1 int64=4591870180066957722)" + "\n"
+
+	require.Equal(t, o, w.String())
+}
+
+func TestInspectWithoutSchemaNested(t *testing.T) {
+	test := testv1.Test{
+		Bytes: []byte{0xff, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05},
+		Test2: &testv1.Test2{Name: "bob"},
+	}
+
+	raw, err := proto.Marshal(&test)
+	require.Nil(t, err)
+
+	w := bytes.NewBuffer(nil)
+	err = NewInspector().InspectWithoutSchema(false, raw, w)
+	require.Nil(t, err)
+
+	o := ""
+	o += "  0: t=  8 bytes [7] ff 00 01 .. 03 04 05" + "\n"
+	o += "  9: t= 10 message [5] (packed varint=[10 3 98 111 98])" + "\n"
+	o += `   11: t=  1 string [3] "bob" (packed varint=[98 111 98])` + "\n"
+
+	require.Equal(t, o, w.String())
+}
+
+func TestReportWithSchema(t *testing.T) {
+	test := testv1.Test{
+		Int32: 1,
+		Bytes: []byte("haha"),
+		Test2: &testv1.Test2{Name: "bob"},
+	}
+
+	raw, err := proto.Marshal(&test)
+	require.Nil(t, err)
+
+	in := NewInspector()
+	require.Nil(t, in.ReadSchemaFromFile("../proto/test/v1/test.proto"))
+
+	d := NewDecoder(in.definition, NewBuffer(raw))
+	_, err = d.Decode("test.v1", "Test")
+	require.Nil(t, err)
+
+	spans := d.Spans()
+	require.Len(t, spans, 3)
+	require.Equal(t, "int32", spans[0].Name)
+	require.Equal(t, []interface{}{int32(1)}, spans[0].Values)
+	require.Equal(t, 0, spans[0].Start)
+	require.Equal(t, 2, spans[0].End)
+	require.Equal(t, "test2", spans[2].Name)
+	require.Equal(t, 8, spans[2].Start)
+	require.Equal(t, 15, spans[2].End)
+	require.Len(t, spans[2].Children, 1)
+	require.Equal(t, "name", spans[2].Children[0].Name)
+	require.Equal(t, 10, spans[2].Children[0].Start)
+	require.Equal(t, 15, spans[2].Children[0].End)
+
+	w := bytes.NewBuffer(nil)
+	require.Nil(t, in.ReportWithSchema("test.v1", "Test", raw, w))
+	require.Contains(t, w.String(), `<div class="leaf" data-start="10" data-end="15">name (t=1 bytes)`)
+	require.Contains(t, w.String(), `<span class="value">68 61 68 61</span>`)
+	require.Contains(t, w.String(), `<span class="b" id="b14">62</span>`)
+}
+
+func TestInspectWithoutSchemaPacked(t *testing.T) {
+	w := bytes.NewBuffer(nil)
+
+	// 1: [1, 2, 300] packed varints
+	// 2: [1.5, -2] packed floats
+	// 3: [0xffffffffffffffff] packed fixed64
+	// 4: [8, 1] packed varints which parse as a message too
+	raw := []byte{
+		0x0a, 0x04, 0x01, 0x02, 0xac, 0x02,
+		0x12, 0x08, 0x00, 0x00, 0xc0, 0x3f, 0x00, 0x00, 0x00, 0xc0,
+		0x1a, 0x08, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0x22, 0x02, 0x08, 0x01,
+	}
+	err := NewInspector().InspectWithoutSchema(false, raw, w)
+	require.Nil(t, err)
+
+	o := ""
+	o += "  0: t=  1 bytes [4] 01 02 ac 02 (packed varint=[1 2 300])" + "\n"
+	o += "  6: t=  2 bytes [8] 00 00 c0 .. 00 00 c0 (packed float=[1.5 -2] packed double=[-2.000000474974513])" + "\n"
+	o += " 16: t=  3 bytes [8] ff ff ff .. ff ff ff (packed fix32=[4294967295 4294967295] packed fix64=[18446744073709551615])" + "\n"
+	o += " 26: t=  4 message [2] (packed varint=[8 1])" + "\n"
+	o += "   28: t=  1 varint 1 (int64=1 sint64=-1 bool=true)" + "\n"
+
+	require.Equal(t, o, w.String())
+}
+
+func TestToMapWithPartialSchema(t *testing.T) {
+	schema := `
+syntax = "proto3";
+
+package envelope.v1;
+
+message Envelope {
+  string kind = 1;
+  Missing payload = 2;
+  bytes data = 3;
+  repeated Item items = 4;
+}
+
+message Item {
+  int32 id = 1;
+}
+`
+	raw := []byte{
+		0x0a, 0x01, 0x78, // kind
+		0x12, 0x03, 0x08, 0x96, 0x01, // payload of unresolved type
+		0x1a, 0x05, 0x0a, 0x03, 0x62, 0x6f, 0x62, // data
+		0x22, 0x02, 0x08, 0x01, // items
+		0x22, 0x02, 0x08, 0x02, // items
+		0x48, 0x07, // unknown
+		0x48, 0x08, // unknown
+	}
+
+	in := NewInspector()
+	require.Nil(t, in.ReadSchemaFromReader("envelope.proto", strings.NewReader(schema)))
+
+	m, err := in.ToMapWithSchema("envelope.v1", "Envelope", raw)
+	require.Nil(t, err)
+	require.Equal(t, map[string]interface{}{
+		"kind":    "x",
+		"payload": map[string]interface{}{"1": uint64(150)},
+		"data":    []byte{0x0a, 0x03, 0x62, 0x6f, 0x62},
+		"items": []interface{}{
+			map[string]interface{}{"id": int32(1)},
+			map[string]interface{}{"id": int32(2)},
+		},
+		"9": []interface{}{uint64(7), uint64(8)},
+	}, m)
+
+	in.SetDecodeBytes(true)
+	m, err = in.ToMapWithSchema("envelope.v1", "Envelope", raw)
+	require.Nil(t, err)
+	require.Equal(t, map[string]interface{}{"1": "bob"}, m["data"])
+
+	// a truncated unknown field ends the message like a truncated known one
+	_, err = in.ToMapWithSchema("envelope.v1", "Envelope", []byte{0x48, 0x96})
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+	m, err = in.ToMapWithSchema("envelope.v1", "Envelope", []byte{0x22, 0x04, 0x08, 0x01, 0x48, 0x96})
+	require.Nil(t, err)
+	require.Equal(t, map[string]interface{}{
+		"items": []interface{}{map[string]interface{}{"id": int32(1)}},
+	}, m)
+}
+
+func TestToMapWithSchemaRepeated(t *testing.T) {
+	schema := `
+syntax = "proto3";
+
+package envelope.v1;
+
+message Envelope {
+  repeated bytes blobs = 1;
+  repeated Item items = 2;
+  Status status = 3;
+}
+
+message Item {
+  int32 id = 1;
+}
+
+enum Status {
+  UNKNOWN = 0;
+  OK = 1;
+}
+`
+	in := NewInspector()
+	require.Nil(t, in.ReadSchemaFromReader("envelope.proto", strings.NewReader(schema)))
+
+	raw := []byte{
+		0x0a, 0x04, 'h', 'a', 'h', 'a', // blobs
+		0x0a, 0x00, // blobs
+		0x12, 0x02, 0x08, 0x01, // items
+		0x12, 0x00, // items
+		0x18, 0x01, // status
+	}
+	m, err := in.ToMapWithSchema("envelope.v1", "Envelope", raw)
+	require.Nil(t, err)
+	require.Equal(t, map[string]interface{}{
+		"blobs": []interface{}{[]byte("haha"), []byte{}},
+		"items": []interface{}{
+			map[string]interface{}{"id": int32(1)},
+			map[string]interface{}{},
+		},
+		"status": "OK",
+	}, m)
+
+	_, err = in.ToMapWithSchema("envelope.v1", "Envelope", []byte{0x18, 0x02})
+	require.Equal(t, "unknown enum field value:2", err.Error())
+}
+
+func TestMethodMessage(t *testing.T) {
+	schema := `
+syntax = "proto3";
+
+package echo.v1;
+
+import "test/v1/test.proto";
+
+message EchoRequest {
+  string message = 1;
+}
+
+service Echo {
+  rpc Echo (EchoRequest) returns (test.v1.Test2);
+  rpc Missing (EchoRequest) returns (Unknown);
+}
+`
+	in := NewInspector()
+	require.Nil(t, in.ReadSchemaFromFile("../proto/test/v1/test.proto"))
+	require.Nil(t, in.ReadSchemaFromReader("echo.proto", strings.NewReader(schema)))
+
+	pkg, name, err := in.MethodMessage("echo.v1.Echo/Echo", false)
+	require.Nil(t, err)
+	require.Equal(t, []string{"echo.v1", "EchoRequest"}, []string{pkg, name})
+
+	pkg, name, err = in.MethodMessage("/echo.v1.Echo/Echo", true)
+	require.Nil(t, err)
+	require.Equal(t, []string{"test.v1", "Test2"}, []string{pkg, name})
+
+	_, _, err = in.MethodMessage("echo.v1.Echo/Missing", true)
+	require.Equal(t, "inspector: unknown message Unknown of method echo.v1.Echo/Missing", err.Error())
+
+	_, _, err = in.MethodMessage("echo.v1.Echo/Other", false)
+	require.Equal(t, "inspector: unknown method echo.v1.Echo/Other", err.Error())
+}
+
+func TestToMapWithSchemaInvalidUTF8(t *testing.T) {
+	schema := `
+syntax = "proto3";
+
+package envelope.v1;
+
+message Envelope {
+  string name = 1;
+  repeated string tags = 2;
+  map<string, string> attrs = 3;
+}
+`
+	in := NewInspector()
+	require.Nil(t, in.ReadSchemaFromReader("envelope.proto", strings.NewReader(schema)))
+
+	raw := []byte{
+		0x0a, 0x03, 'a', 0xff, 'b',
+		0x12, 0x01, 'x',
+		0x12, 0x01, 0x80,
+		0x1a, 0x06, 0x0a, 0x01, 0xfe, 0x12, 0x01, 'v',
+	}
+	m, err := in.ToMapWithSchema("envelope.v1", "Envelope", raw)
+	require.Nil(t, err)
+	require.Equal(t, map[string]interface{}{
+		"name":  InvalidUTF8("a\xffb"),
+		"tags":  []interface{}{"x", InvalidUTF8("\x80")},
+		"attrs": map[string]interface{}{`"\xfe" (invalid utf-8)`: "v"},
+	}, m)
+	require.Equal(t, `"a\xffb"`, fmt.Sprint(m["name"]))
+
+	data, err := json.Marshal(m)
+	require.Nil(t, err)
+	require.Equal(t, `{"attrs":{"\"\\xfe\" (invalid utf-8)":"v"},"name":{"invalidUtf8":"Yf9i"},"tags":["x",{"invalidUtf8":"gA=="}]}`, string(data))
+
+	// the JSON output encodes back to the same bytes
+	encoded, err := in.EncodeJSON("envelope.v1", "Envelope", data)
+	require.Nil(t, err)
+	require.Equal(t, raw, encoded)
+
+	// keys like the ones of invalid UTF-8 which unquote to valid UTF-8 are kept
+	encoded, err = in.EncodeJSON("envelope.v1", "Envelope", []byte(`{"attrs":{"\"k\" (invalid utf-8)":"v"}}`))
+	require.Nil(t, err)
+	require.Equal(t, append([]byte{0x1a, 0x18, 0x0a, 0x13}, `"k" (invalid utf-8)`+"\x12\x01v"...), encoded)
+
+	w := bytes.NewBuffer(nil)
+	require.Nil(t, in.ReportWithSchema("envelope.v1", "Envelope", raw[:5], w))
+	require.Contains(t, w.String(), `&#34;a\xffb&#34; (invalid utf-8)`)
+}
+
+func TestToMapWithSchemaNestedTypes(t *testing.T) {
+	schema := `
+syntax = "proto3";
+
+package nested.v1;
+
+message Outer {
+  message Inner {
+    int32 x = 1;
+  }
+  enum Kind {
+    UNKNOWN = 0;
+    ITEM = 1;
+  }
+}
+
+message Msg {
+  Outer.Inner d = 1;
+  .nested.v1.Outer.Inner e = 2;
+  Outer.Kind kind = 3;
+}
+`
+	in := NewInspector()
+	require.Nil(t, in.ReadSchemaFromReader("nested.proto", strings.NewReader(schema)))
+
+	raw, err := in.EncodeJSON("nested.v1", "Msg", []byte(`{"d":{"x":1},"e":{"x":2},"kind":"ITEM"}`))
+	require.Nil(t, err)
+	m, err := in.ToMapWithSchema("nested.v1", "Msg", raw)
+	require.Nil(t, err)
+	require.Equal(t, map[string]interface{}{
+		"d":    map[string]interface{}{"x": int32(1)},
+		"e":    map[string]interface{}{"x": int32(2)},
+		"kind": "ITEM",
+	}, m)
+}
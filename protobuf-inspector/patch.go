@@ -0,0 +1,285 @@
+package inspector
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	pp "github.com/emicklei/proto"
+	pb "github.com/golang/protobuf/proto"
+)
+
+// Edit is a change made by Patch: the field at Path, like test2.name,
+// items[1] or attrs["k"], is set to Value, a JSON value following the
+// protojson conventions, or deleted
+type Edit struct {
+	Path   string
+	Value  string
+	Delete bool
+}
+
+// ParseEdit parses edits like test2.name="bob", int32=7 or delete bytes
+func ParseEdit(s string) (Edit, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "delete ") {
+		return Edit{Path: strings.TrimSpace(strings.TrimPrefix(s, "delete ")), Delete: true}, nil
+	}
+
+	// the = which is not in a map key
+	quoted := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case '=':
+			if !quoted {
+				return Edit{Path: strings.TrimSpace(s[:i]), Value: strings.TrimSpace(s[i+1:])}, nil
+			}
+		}
+	}
+	return Edit{}, fmt.Errorf("inspector: invalid edit %s, expected path=value or delete path", s)
+}
+
+// Patch applies edits to raw, a message pkg.name, rewriting only the fields
+// they change. The other fields, unknown ones included, keep their bytes and
+// order; new fields are inserted before the first field with a greater
+// number.
+func (e *Encoder) Patch(pkg, name string, raw []byte, edits []Edit) ([]byte, error) {
+	m, ok := e.d.Message(pkg, name)
+	if !ok {
+		return nil, fmt.Errorf("no definition found for package [%s] type [%s]", pkg, name)
+	}
+
+	for _, edit := range edits {
+		segs, err := parsePath(edit.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		var v interface{}
+		if !edit.Delete {
+			dec := json.NewDecoder(strings.NewReader(edit.Value))
+			dec.UseNumber()
+			if err := dec.Decode(&v); err != nil {
+				return nil, &EncodeError{Path: edit.Path, Err: fmt.Errorf("invalid value %s: %v", edit.Value, err)}
+			}
+		}
+
+		raw, err = e.patchMessage(pkg, m, raw, segs, v, edit.Delete, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return raw, nil
+}
+
+// patchMessage sets the field at segs of raw, a message m, to v or deletes it
+func (e *Encoder) patchMessage(pkg string, m *pp.Message, raw []byte, segs []pathSegment, v interface{}, del bool, path string) ([]byte, error) {
+	seg := segs[0]
+	path = joinPath(path, seg.String())
+
+	var f *fieldInfo
+	for _, each := range messageFields(m) {
+		if each.name == seg.name || each.jsonName == seg.name {
+			f = each
+		}
+	}
+	if f == nil {
+		return nil, &EncodeError{Path: path, Err: fmt.Errorf("unknown field of %s", m.Name)}
+	}
+
+	spans, err := scanFields(raw)
+	if err != nil {
+		return nil, &EncodeError{Path: path, Err: fmt.Errorf("cannot patch malformed message %v", err)}
+	}
+	var occurrences []int
+	for i, s := range spans {
+		if s.number == f.number {
+			occurrences = append(occurrences, i)
+		}
+	}
+
+	// target is the span to replace or -1 to insert, all replaces every
+	// occurrence and drop the spans to delete besides target
+	target, all := -1, false
+	drop := map[int]bool{}
+	switch seg.selector {
+	case selectNone:
+		if len(segs) > 1 && f.repeated {
+			return nil, &EncodeError{Path: path, Err: fmt.Errorf("index of repeated field required")}
+		}
+		all = len(segs) == 1
+		if len(occurrences) > 0 {
+			target = occurrences[len(occurrences)-1]
+			if all {
+				target = occurrences[0]
+			}
+		}
+
+	case selectIndex:
+		if f.keyType != "" {
+			break
+		}
+		if !f.repeated {
+			return nil, &EncodeError{Path: path, Err: fmt.Errorf("index of non-repeated field")}
+		}
+		if f.packed && e.packable(pkg, f.typ) {
+			return nil, &EncodeError{Path: path, Err: fmt.Errorf("index of packed field")}
+		}
+		if seg.index >= len(occurrences) {
+			return nil, &EncodeError{Path: path, Err: fmt.Errorf("index out of range [0, %d)", len(occurrences))}
+		}
+		target = occurrences[seg.index]
+
+	case selectAll:
+		return nil, &EncodeError{Path: path, Err: fmt.Errorf("cannot patch all elements")}
+	}
+
+	var entry *pp.Message
+	if seg.selector == selectKey || (seg.selector == selectIndex && f.keyType != "") {
+		if f.keyType == "" {
+			return nil, &EncodeError{Path: path, Err: fmt.Errorf("key of non-map field")}
+		}
+		entry = mapEntryMessage(f)
+		key := pb.NewBuffer(nil)
+		k, err := mapKey(f.keyType, seg.key)
+		if err == nil {
+			err = e.encodeValue(key, pkg, 1, f.keyType, k, path)
+		}
+		if err != nil {
+			return nil, &EncodeError{Path: path, Err: fmt.Errorf("invalid key %s", seg.key)}
+		}
+		want, _ := entryKey(f.keyType, key.Bytes())
+		zero := pb.NewBuffer(nil)
+		k, _ = mapKey(f.keyType, defaultKey(f.keyType))
+		e.encodeValue(zero, pkg, 1, f.keyType, k, path)
+		for _, i := range occurrences {
+			s := spans[i]
+			if s.wire != pb.WireBytes {
+				continue
+			}
+			got, ok := entryKey(f.keyType, raw[s.data:s.end])
+			if !ok {
+				// the key of an entry without key field is the default
+				if _, err := scanFields(raw[s.data:s.end]); err != nil {
+					continue
+				}
+				got, _ = entryKey(f.keyType, zero.Bytes())
+			}
+			if got == want {
+				// the last entry of a key wins, deleting drops all of them
+				target = i
+				drop[i] = del && len(segs) == 1
+			}
+		}
+	}
+
+	var replacement []byte
+	switch {
+	case len(segs) > 1 || entry != nil:
+		// patch the embedded message or map entry
+		sub, mpkg := entry, pkg
+		subSegs := segs[1:]
+		if entry != nil {
+			subSegs = append([]pathSegment{{name: "value"}}, subSegs...)
+		} else {
+			var ok bool
+			if mpkg, sub, ok = e.d.resolveMessage(pkg, f.typ); !ok {
+				return nil, &EncodeError{Path: path, Err: fmt.Errorf("field of %s which is not a message", f.typ)}
+			}
+		}
+
+		var tag, payload []byte
+		if target >= 0 {
+			s := spans[target]
+			if s.wire != pb.WireBytes {
+				return nil, &EncodeError{Path: path, Err: fmt.Errorf("field of wire type %d which is not a message", s.wire)}
+			}
+			tag, payload = raw[s.start:s.data], raw[s.data:s.end]
+			// keep the bytes of the tag, the length changes
+			b := NewBuffer(tag)
+			b.DecodeVarint()
+			tag = tag[:b.index]
+		} else if del {
+			return raw, nil
+		} else {
+			tag = appendVarint(nil, uint64(f.number)<<3|pb.WireBytes, 0)
+			if entry != nil {
+				k, _ := mapKey(f.keyType, seg.key)
+				key := pb.NewBuffer(nil)
+				e.encodeValue(key, pkg, 1, f.keyType, k, path)
+				payload = key.Bytes()
+			}
+		}
+
+		if entry != nil && del && len(segs) == 1 {
+			break
+		}
+		patched, err := e.patchMessage(mpkg, sub, payload, subSegs, v, del, path)
+		if err != nil {
+			return nil, err
+		}
+		replacement = append(append(append([]byte(nil), tag...), appendVarint(nil, uint64(len(patched)), 0)...), patched...)
+
+	case del:
+		// nothing replaces the deleted field
+
+	default:
+		b := pb.NewBuffer(nil)
+		if seg.selector == selectIndex {
+			err = e.encodeValue(b, pkg, f.number, f.typ, v, path)
+		} else {
+			err = e.encodeField(b, pkg, f, v, path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		replacement = b.Bytes()
+	}
+
+	// rebuild the message from the spans
+	var out []byte
+	inserted := target >= 0
+	for i, s := range spans {
+		if !inserted && s.number > f.number {
+			out = append(out, replacement...)
+			inserted = true
+		}
+		switch {
+		case i == target:
+			out = append(out, replacement...)
+		case all && s.number == f.number, drop[i]:
+		default:
+			out = append(out, raw[s.start:s.end]...)
+		}
+	}
+	if !inserted {
+		out = append(out, replacement...)
+	}
+	return out, nil
+}
+
+// defaultKey returns the default key of keyType, which entries without key
+// field have
+func defaultKey(keyType string) string {
+	switch keyType {
+	case "string":
+		return ""
+	case "bool":
+		return "false"
+	}
+	return "0"
+}
+
+// mapEntryMessage returns the message of the entries of the map field f
+func mapEntryMessage(f *fieldInfo) *pp.Message {
+	return &pp.Message{
+		Name: f.name + ".Entry",
+		Elements: []pp.Visitee{
+			&pp.NormalField{Field: &pp.Field{Name: "key", Type: f.keyType, Sequence: 1}},
+			&pp.NormalField{Field: &pp.Field{Name: "value", Type: f.typ, Sequence: 2}},
+		},
+	}
+}
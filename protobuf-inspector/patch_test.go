@@ -0,0 +1,134 @@
+package inspector
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPatch(t *testing.T) {
+	in := NewInspector()
+	require.Nil(t, in.ReadSchemaFromFile("../proto/test/v1/test.proto"))
+
+	raw := []byte{
+		0x08, 0x81, 0x00, // int32 as a long varint
+		0x48, 0x07, // unknown
+		0x52, 0x05, 0x0a, 0x03, 0x62, 0x6f, 0x62, // test2
+		0x42, 0x01, 0x78, // bytes
+	}
+
+	edits := []string{`test2.name="alice"`, "int32=7", "delete bytes", "uint32=5"}
+	patched, err := in.Patch("test.v1", "Test", raw, edits)
+	require.Nil(t, err)
+	require.Equal(t, []byte{
+		0x08, 0x07,
+		0x28, 0x05,
+		0x48, 0x07,
+		0x52, 0x07, 0x0a, 0x05, 0x61, 0x6c, 0x69, 0x63, 0x65,
+	}, patched)
+
+	_, err = in.Patch("test.v1", "Test", raw, []string{"test2.nam=1"})
+	require.Equal(t, "inspector: test2.nam: unknown field of Test2", err.Error())
+	_, err = in.Patch("test.v1", "Test", raw, []string{"int32"})
+	require.Equal(t, "inspector: invalid edit int32, expected path=value or delete path", err.Error())
+	_, err = in.Patch("test.v1", "Test", raw, []string{`int32="x"`})
+	require.Equal(t, "inspector: int32: invalid int32 x", err.Error())
+	_, err = in.Patch("test.v1", "Test", []byte{0x08, 0x01, 0x80}, []string{"int32=2"})
+	require.Equal(t, "inspector: int32: cannot patch malformed message [  2] tag err unexpected EOF", err.Error())
+}
+
+func TestPatchCollections(t *testing.T) {
+	schema := `
+syntax = "proto3";
+
+package envelope.v1;
+
+message Envelope {
+  repeated Item items = 1;
+  map<string, Item> attrs = 2;
+  repeated int32 ids = 3;
+}
+
+message Item {
+  int32 id = 1;
+  string name = 2;
+}
+`
+	in := NewInspector()
+	require.Nil(t, in.ReadSchemaFromReader("envelope.proto", strings.NewReader(schema)))
+
+	raw := []byte{
+		0x0a, 0x02, 0x08, 0x01, // items
+		0x0a, 0x04, 0x08, 0x02, 0x48, 0x00, // items with an unknown field
+		0x12, 0x07, 0x0a, 0x01, 0x61, 0x12, 0x02, 0x08, 0x03, // attrs a
+		0x1a, 0x02, 0x01, 0x02, // ids
+	}
+
+	patched, err := in.Patch("envelope.v1", "Envelope", raw, []string{
+		"items[1].id=5",
+		`attrs["a"].name="x"`,
+		`attrs["b"]={"id": 4}`,
+		"ids=[9]",
+	})
+	require.Nil(t, err)
+	require.Equal(t, []byte{
+		0x0a, 0x02, 0x08, 0x01,
+		0x0a, 0x04, 0x08, 0x05, 0x48, 0x00,
+		0x12, 0x0a, 0x0a, 0x01, 0x61, 0x12, 0x05, 0x08, 0x03, 0x12, 0x01, 0x78,
+		0x12, 0x07, 0x0a, 0x01, 0x62, 0x12, 0x02, 0x08, 0x04,
+		0x1a, 0x01, 0x09,
+	}, patched)
+
+	patched, err = in.Patch("envelope.v1", "Envelope", raw, []string{`delete attrs["a"]`, "delete items[0]"})
+	require.Nil(t, err)
+	require.Equal(t, []byte{
+		0x0a, 0x04, 0x08, 0x02, 0x48, 0x00,
+		0x1a, 0x02, 0x01, 0x02,
+	}, patched)
+
+	_, err = in.Patch("envelope.v1", "Envelope", raw, []string{"items[2].id=1"})
+	require.Equal(t, "inspector: items[2]: index out of range [0, 2)", err.Error())
+	_, err = in.Patch("envelope.v1", "Envelope", raw, []string{"items.id=1"})
+	require.Equal(t, "inspector: items: index of repeated field required", err.Error())
+}
+
+func TestPatchMapEntries(t *testing.T) {
+	schema := `
+syntax = "proto3";
+
+package envelope.v1;
+
+message Envelope {
+  map<string, string> attrs = 1;
+  map<int32, string> codes = 2;
+}
+`
+	in := NewInspector()
+	require.Nil(t, in.ReadSchemaFromReader("envelope.proto", strings.NewReader(schema)))
+
+	raw := []byte{
+		0x0a, 0x06, 0x0a, 0x01, 'k', 0x12, 0x01, '1', // attrs k
+		0x0a, 0x06, 0x12, 0x01, '2', 0x0a, 0x01, 'k', // attrs k again, value first
+		0x0a, 0x03, 0x12, 0x01, 'e', // attrs with the default key ""
+		0x12, 0x03, 0x12, 0x01, 'z', // codes with the default key 0
+		0x12, 0x05, 0x08, 0x01, 0x12, 0x01, 'o', // codes 1
+	}
+
+	patched, err := in.Patch("envelope.v1", "Envelope", raw, []string{`delete attrs["k"]`, `codes[0]="y"`})
+	require.Nil(t, err)
+	require.Equal(t, []byte{
+		0x0a, 0x03, 0x12, 0x01, 'e',
+		0x12, 0x03, 0x12, 0x01, 'y',
+		0x12, 0x05, 0x08, 0x01, 0x12, 0x01, 'o',
+	}, patched)
+
+	patched, err = in.Patch("envelope.v1", "Envelope", raw, []string{`attrs["k"]="3"`, `delete attrs[""]`})
+	require.Nil(t, err)
+	require.Equal(t, []byte{
+		0x0a, 0x06, 0x0a, 0x01, 'k', 0x12, 0x01, '1',
+		0x0a, 0x06, 0x12, 0x01, '3', 0x0a, 0x01, 'k',
+		0x12, 0x03, 0x12, 0x01, 'z',
+		0x12, 0x05, 0x08, 0x01, 0x12, 0x01, 'o',
+	}, patched)
+}
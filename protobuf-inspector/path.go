@@ -0,0 +1,86 @@
+package inspector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The selectors of a path segment
+const (
+	selectNone  = iota
+	selectIndex // items[1]
+	selectKey   // attrs["k"]
+	selectAll   // items[*]
+)
+
+// pathSegment is one step of a field path like test2.name, items[*].id or
+// attrs["k"]: a field name optionally followed by a selector in brackets
+type pathSegment struct {
+	name     string
+	selector int
+	index    int
+	key      string
+}
+
+func (s pathSegment) String() string {
+	switch s.selector {
+	case selectIndex:
+		return fmt.Sprintf("%s[%d]", s.name, s.index)
+	case selectKey:
+		return fmt.Sprintf("%s[%s]", s.name, strconv.Quote(s.key))
+	case selectAll:
+		return s.name + "[*]"
+	}
+	return s.name
+}
+
+// parsePath parses a field path
+func parsePath(path string) ([]pathSegment, error) {
+	var segs []pathSegment
+
+	s := path
+	for {
+		n := strings.IndexAny(s, ".[")
+		if n < 0 {
+			n = len(s)
+		}
+		seg := pathSegment{name: s[:n]}
+		if seg.name == "" {
+			return nil, fmt.Errorf("inspector: invalid path %s", path)
+		}
+		s = s[n:]
+
+		if strings.HasPrefix(s, "[") {
+			end := strings.IndexByte(s, ']')
+			if strings.HasPrefix(s, `["`) {
+				// the key may contain ] itself
+				key, n, err := unquoteText(s[1:])
+				if err != nil || !strings.HasPrefix(s[1+n:], "]") {
+					return nil, fmt.Errorf("inspector: invalid key in path %s", path)
+				}
+				seg.selector, seg.key = selectKey, key
+				end = 1 + n
+			} else if end < 0 {
+				return nil, fmt.Errorf("inspector: unclosed [ in path %s", path)
+			} else if sel := s[1:end]; sel == "*" {
+				seg.selector = selectAll
+			} else if i, err := strconv.Atoi(sel); err == nil && i >= 0 {
+				seg.selector, seg.index, seg.key = selectIndex, i, sel
+			} else {
+				// keys of maps which are not keyed by strings
+				seg.selector, seg.key = selectKey, sel
+			}
+			s = s[end+1:]
+		}
+		segs = append(segs, seg)
+
+		if s == "" {
+			return segs, nil
+		}
+		if !strings.HasPrefix(s, ".") {
+			return nil, fmt.Errorf("inspector: invalid path %s", path)
+		}
+		s = s[1:]
+	}
+}
@@ -0,0 +1,797 @@
+package inspector
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+// maxFieldNumber is the largest field number allowed by protobuf.
+const maxFieldNumber = 1<<29 - 1
+
+// maxDepth is the number of groups and embedded messages which may be
+// nested in one another, like the recursion limit of protobuf parsers.
+// Deeper payloads are not decoded as messages.
+const maxDepth = 100
+
+// maxPackedShown is the number of packed values shown when not verbose.
+const maxPackedShown = 8
+
+// The kinds of RawField
+const (
+	KindVarint     = "varint"
+	KindFixed32    = "fix32"
+	KindFixed64    = "fix64"
+	KindBytes      = "bytes"
+	KindString     = "string"
+	KindMessage    = "message"
+	KindStartGroup = "start"
+	KindEndGroup   = "end"
+	KindDamaged    = "damaged"
+)
+
+// RawMessage is a message decoded without schema
+type RawMessage struct {
+	Fields      []*RawField  `json:"fields"`
+	Diagnostics []Diagnostic `json:"diagnostics,omitempty"`
+}
+
+// Diagnostic is a problem found in the byte range [Offset, End) of a message
+// decoded without schema
+type Diagnostic struct {
+	Offset  int    `json:"offset"`
+	End     int    `json:"end"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// The kinds of Diagnostic
+const (
+	DiagnosticDamaged          = "damaged"
+	DiagnosticReservedWireType = "reserved wire type"
+	DiagnosticMismatchedGroup  = "mismatched group"
+	DiagnosticUnclosedGroup    = "unclosed group"
+	DiagnosticUnexpectedGroup  = "unexpected end group"
+	DiagnosticTooDeep          = "too deep"
+)
+
+// RawField is a field decoded without schema. Offset and End are the byte
+// range [Offset, End) of the field, tag included, in the outermost message.
+//
+// Raw holds the encoded value of scalars and the payload of length-delimited
+// fields, Value the number of varint and fixed fields. Their plausible
+// interpretations are returned by Candidates. Embedded messages and
+// groups hold their fields in Children; the end-group tag, if any, is the
+// last child of its group. Damaged fields hold the bytes which were skipped
+// to resynchronize in Raw and the reason in Error.
+type RawField struct {
+	Offset   int         `json:"offset"`
+	End      int         `json:"end"`
+	Tag      uint64      `json:"tag"`
+	Wire     uint64      `json:"wire"`
+	Kind     string      `json:"kind"`
+	Raw      []byte      `json:"raw,omitempty"`
+	Value    uint64      `json:"value"`
+	Children []*RawField `json:"children,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// Candidates returns the plausible interpretations of the value of the
+// field. They are built on demand since the payloads of embedded messages
+// are nested in one another.
+func (f *RawField) Candidates() []Candidate {
+	switch f.Kind {
+	case KindVarint:
+		return varintCandidates(f.Value)
+	case KindFixed32:
+		return fixed32Candidates(f.Value)
+	case KindFixed64:
+		return fixed64Candidates(f.Value)
+	case KindMessage:
+		var candidates []Candidate
+		if isText(f.Raw) {
+			candidates = append(candidates, Candidate{Type: "string", Value: string(f.Raw)})
+		}
+		// packed repeated fields often parse as messages or text as well
+		return append(candidates, packedCandidates(f.Raw)...)
+	case KindString, KindBytes:
+		return packedCandidates(f.Raw)
+	}
+	return nil
+}
+
+// MarshalJSON encodes the field along with its candidates
+func (f *RawField) MarshalJSON() ([]byte, error) {
+	type rawField RawField
+	return json.Marshal(struct {
+		*rawField
+		Candidates []Candidate `json:"candidates,omitempty"`
+	}{(*rawField)(f), f.Candidates()})
+}
+
+// Candidate is a plausible interpretation of a value decoded without schema
+type Candidate struct {
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+}
+
+// MarshalJSON encodes the candidate, writing the floats which JSON cannot
+// represent as strings
+func (c Candidate) MarshalJSON() ([]byte, error) {
+	v := c.Value
+	switch f := v.(type) {
+	case float32:
+		if math.IsNaN(float64(f)) || math.IsInf(float64(f), 0) {
+			v = formatFloat(float64(f), 32)
+		}
+	case float64:
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			v = formatFloat(f, 64)
+		}
+	}
+	return json.Marshal(struct {
+		Type  string      `json:"type"`
+		Value interface{} `json:"value"`
+	}{c.Type, v})
+}
+
+// DecodeWithoutSchema decodes raw protobuf binary data without schema. On
+// error it returns the fields decoded before the error as well.
+func DecodeWithoutSchema(raw []byte) (*RawMessage, error) {
+	p := NewBuffer(raw)
+	fields, err := p.decodeRawFields(0, nil)
+	return &RawMessage{Fields: fields, Diagnostics: p.sortedDiagnostics()}, err
+}
+
+// RecoverWithoutSchema decodes raw protobuf binary data without schema like
+// DecodeWithoutSchema, but instead of stopping at a malformed field it marks
+// the bytes up to the next plausible field as damaged and keeps going. Every
+// damaged range is reported in the diagnostics.
+func RecoverWithoutSchema(raw []byte) *RawMessage {
+	p := NewBuffer(raw)
+	p.recover = true
+	fields, _ := p.decodeRawFields(0, nil)
+	return &RawMessage{Fields: fields, Diagnostics: p.sortedDiagnostics()}
+}
+
+// sortedDiagnostics returns the diagnostics of p in the order of offsets,
+// as a group is found unclosed after the groups in it.
+func (p *Buffer) sortedDiagnostics() []Diagnostic {
+	sort.SliceStable(p.diagnostics, func(i, j int) bool {
+		return p.diagnostics[i].Offset < p.diagnostics[j].Offset
+	})
+	return p.diagnostics
+}
+
+// diagnose adds a diagnostic of kind for [offset, end) to p.
+func (p *Buffer) diagnose(offset, end int, kind string, format string, args ...interface{}) {
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		Offset:  offset,
+		End:     end,
+		Kind:    kind,
+		Message: kind + ": " + fmt.Sprintf(format, args...),
+	})
+}
+
+// diagnoseFailure adds a diagnostic for the field at start of p which failed
+// to decode with cause, [start, end) being the bytes lost to it.
+func (p *Buffer) diagnoseFailure(base, start, end int, cause error) {
+	kind := DiagnosticDamaged
+	p.index = start
+	if _, ok := cause.(depthError); ok {
+		kind = DiagnosticTooDeep
+	} else if op, err := p.DecodeVarint(); err == nil && op&7 > proto.WireFixed32 {
+		kind = DiagnosticReservedWireType
+	}
+	p.diagnose(base+start, base+end, kind, "%v", cause)
+}
+
+// decodeRawFields decodes the fields of p whose buf starts at base of the
+// outermost message. The fields end at the end of p or, in a group, after
+// the end-group tag. The open groups are the stack of calls with a group,
+// which is at most maxDepth deep.
+func (p *Buffer) decodeRawFields(base int, group *RawField) ([]*RawField, error) {
+	var fields []*RawField
+
+	for p.index < len(p.buf) {
+		index := p.index
+		f, err := p.decodeRawField(base)
+		if err != nil && p.recover {
+			fields = append(fields, p.resync(base, index, group != nil, err))
+			continue
+		}
+		if err != nil {
+			if !p.failed {
+				// only the innermost group reports the failure
+				p.failed = true
+				p.diagnoseFailure(base, index, len(p.buf), err)
+				p.index = len(p.buf)
+			}
+			return fields, err
+		}
+		fields = append(fields, f)
+
+		if f.Wire != proto.WireEndGroup {
+			continue
+		}
+		if group == nil {
+			p.diagnose(f.Offset, f.End, DiagnosticUnexpectedGroup, "t=%d has no start group", f.Tag)
+			continue
+		}
+		if f.Tag != group.Tag {
+			p.diagnose(f.Offset, f.End, DiagnosticMismatchedGroup,
+				"end t=%d does not match start t=%d at %d", f.Tag, group.Tag, group.Offset)
+		}
+		return fields, nil
+	}
+
+	if group != nil {
+		p.diagnose(group.Offset, base+p.index, DiagnosticUnclosedGroup, "start t=%d is never ended", group.Tag)
+	}
+	return fields, nil
+}
+
+// resync skips the bytes from start, where decoding failed with cause, up to
+// the next offset which decodes as a plausible field and returns the skipped
+// bytes as a damaged field. Field numbers 1 to 15, whose tags are a single
+// byte, are by far the most common, so they are looked for first.
+func (p *Buffer) resync(base, start int, group bool, cause error) *RawField {
+	next := p.nextPlausibleField(base, start+1, group, true)
+	if next == len(p.buf) {
+		next = p.nextPlausibleField(base, start+1, group, false)
+	}
+	p.diagnoseFailure(base, start, next, cause)
+	p.index = next
+
+	return &RawField{
+		Offset: base + start,
+		End:    base + next,
+		Kind:   KindDamaged,
+		Raw:    p.buf[start:next],
+		Error:  cause.Error(),
+	}
+}
+
+// nextPlausibleField returns the first offset from start which decodes as a
+// field, with a single byte tag if short, or the end of p.
+func (p *Buffer) nextPlausibleField(base, start int, group bool, short bool) int {
+	// the trial decoding neither recovers nor reports
+	diagnostics := p.diagnostics
+	p.recover = false
+	defer func() {
+		p.recover = true
+		p.failed = false
+		p.diagnostics = diagnostics
+	}()
+
+	for next := start; next < len(p.buf); next++ {
+		// no group can start past the depth limit
+		if p.depth == maxDepth && p.buf[next]&7 == proto.WireStartGroup {
+			continue
+		}
+		p.index = next
+		f, err := p.decodeRawField(base)
+		if err != nil || f.Tag == 0 || f.Tag > maxFieldNumber {
+			continue
+		}
+		if short && p.buf[next] >= 0x80 {
+			continue
+		}
+		// a stray end-group tag is weak evidence of a field boundary
+		if f.Wire == proto.WireEndGroup && !group {
+			continue
+		}
+		return next
+	}
+	return len(p.buf)
+}
+
+// decodeRawField decodes the field at the read point of p.
+func (p *Buffer) decodeRawField(base int) (*RawField, error) {
+	index := p.index
+
+	// Each key in the streamed message is a varint with the value (field_number << 3) | wire_type –
+	// in other words, the last three bits of the number store the wire type.
+	op, err := p.DecodeVarint()
+	if err != nil {
+		return nil, rawError(err, "insepctor: [%3d] fetching op err %v", base+index, err)
+	}
+
+	f := &RawField{
+		Offset: base + index,
+		Tag:    op >> 3,
+		Wire:   op & 7,
+	}
+	if err := p.decodeRawValue(f, base); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// decodeRawValue decodes the value of f, whose tag was just read, from p.
+// Like protoc --decode_raw, length-delimited values are an embedded message
+// if they parse cleanly, then a string and opaque bytes otherwise.
+func (p *Buffer) decodeRawValue(f *RawField, base int) error {
+	var (
+		err   error
+		index = f.Offset
+		tag   = f.Tag
+	)
+
+	switch f.Wire {
+	default:
+		return fmt.Errorf("Buffer: [%3d] t=%3d unknown wire=%d", index, tag, f.Wire)
+
+	case proto.WireBytes:
+		var r []byte
+
+		r, err = p.DecodeRawBytes(true)
+		if err != nil {
+			return rawError(err, "insepctor: [%3d] t=%3d bytes err %v", index, tag, err)
+		}
+		f.Raw = r
+
+		switch {
+		case p.depth < maxDepth && isMessage(r):
+			f.Kind = KindMessage
+			sub := NewBuffer(r)
+			sub.depth = p.depth + 1
+			f.Children, _ = sub.decodeRawFields(base+p.index-len(r), nil)
+		case isText(r):
+			f.Kind = KindString
+		default:
+			f.Kind = KindBytes
+		}
+
+	case proto.WireFixed32:
+		start := p.index
+		f.Value, err = p.DecodeFixed32()
+		if err != nil {
+			return rawError(err, "insepctor: [%3d] t=%3d fix32 err %v", index, tag, err)
+		}
+		f.Kind = KindFixed32
+		f.Raw = p.buf[start:p.index]
+
+	case proto.WireFixed64:
+		start := p.index
+		f.Value, err = p.DecodeFixed64()
+		if err != nil {
+			return rawError(err, "insepctor: [%3d] t=%3d fix64 err %v", index, tag, err)
+		}
+		f.Kind = KindFixed64
+		f.Raw = p.buf[start:p.index]
+
+	case proto.WireVarint:
+		start := p.index
+		f.Value, err = p.DecodeVarint()
+		if err != nil {
+			return rawError(err, "%3d: t=%3d varint err %v", index, tag, err)
+		}
+		f.Kind = KindVarint
+		f.Raw = p.buf[start:p.index]
+
+	case proto.WireStartGroup:
+		if p.depth == maxDepth {
+			return depthError{fmt.Errorf("insepctor: [%3d] t=%3d groups nested deeper than %d", index, tag, maxDepth)}
+		}
+		f.Kind = KindStartGroup
+		p.depth++
+		f.Children, err = p.decodeRawFields(base, f)
+		p.depth--
+		if err != nil {
+			return err
+		}
+
+	case proto.WireEndGroup:
+		f.Kind = KindEndGroup
+	}
+
+	f.End = base + p.index
+	return nil
+}
+
+// truncatedError is the error of a field which runs past the end of the
+// data. The Decoder passes it on as io.ErrUnexpectedEOF.
+type truncatedError struct {
+	error
+}
+
+// depthError is the error of a group which is nested too deep to decode.
+type depthError struct {
+	error
+}
+
+// rawError formats the error of a field which failed to decode with err
+func rawError(err error, format string, args ...interface{}) error {
+	if err == io.ErrUnexpectedEOF {
+		return truncatedError{fmt.Errorf(format, args...)}
+	}
+	return fmt.Errorf(format, args...)
+}
+
+// Interface returns the value of the field as a Go value: a number for
+// varint and fixed fields, a string for text, bytes for other payloads and
+// a map keyed by tag numbers for embedded messages and groups.
+func (f *RawField) Interface() interface{} {
+	switch f.Kind {
+	case KindVarint, KindFixed64:
+		return f.Value
+	case KindFixed32:
+		return uint32(f.Value)
+	case KindString:
+		return string(f.Raw)
+	case KindMessage:
+		// printable text which happens to parse as a message is much more
+		// common than messages made of printable bytes only
+		if isText(f.Raw) {
+			return string(f.Raw)
+		}
+		return rawFieldsToMap(f.Children)
+	case KindStartGroup:
+		return rawFieldsToMap(f.Children)
+	case KindBytes:
+		return f.Raw
+	}
+	return nil
+}
+
+// ToMap maps the fields to map[string]interface{} keyed by tag numbers
+func (m *RawMessage) ToMap() map[string]interface{} {
+	return rawFieldsToMap(m.Fields)
+}
+
+func rawFieldsToMap(fields []*RawField) map[string]interface{} {
+	m := map[string]interface{}{}
+	for _, f := range fields {
+		if f.Kind == KindEndGroup {
+			continue
+		}
+		v := f.Interface()
+		key := strconv.FormatUint(f.Tag, 10)
+		if val, ok := m[key]; ok {
+			// the same field again means it is repeated
+			if list, ok := val.([]interface{}); ok {
+				m[key] = append(list, v)
+			} else {
+				m[key] = []interface{}{val, v}
+			}
+		} else {
+			m[key] = v
+		}
+	}
+	return m
+}
+
+// WriteText writes the fields as indented text lines to w. If verbose, the
+// payloads are written in full.
+func (m *RawMessage) WriteText(w io.Writer, verbose bool) {
+	writeRawFields(w, m.Fields, 0, verbose)
+
+	for _, d := range m.Diagnostics {
+		fmt.Fprintf(w, "%3d: [%d, %d) %s\n", d.Offset, d.Offset, d.End, d.Message)
+	}
+}
+
+func writeRawFields(w io.Writer, fields []*RawField, depth int, verbose bool) {
+	for _, f := range fields {
+		indent := depth
+		if f.Kind == KindEndGroup && indent > 0 {
+			// the end-group tag lines up with its start-group tag
+			indent--
+		}
+		if f.Kind == KindDamaged {
+			fmt.Fprintf(w, "%s%3d: %s\n", strings.Repeat("  ", indent), f.Offset, f.Text(verbose))
+			continue
+		}
+		fmt.Fprintf(w, "%s%3d: t=%3d %s\n", strings.Repeat("  ", indent), f.Offset, f.Tag, f.Text(verbose))
+		writeRawFields(w, f.Children, depth+1, verbose)
+	}
+}
+
+// Text returns the kind, the value and the candidates of the field as text.
+// If verbose, the payloads are written in full.
+func (f *RawField) Text(verbose bool) string {
+	var s string
+
+	switch f.Kind {
+	case KindVarint, KindFixed32, KindFixed64:
+		s = fmt.Sprintf("%s %d", f.Kind, f.Value)
+	case KindMessage:
+		s = fmt.Sprintf("message [%d]", len(f.Raw))
+	case KindString:
+		s = fmt.Sprintf("string [%d] %q", len(f.Raw), f.Raw)
+	case KindBytes:
+		s = fmt.Sprintf("bytes [%d]%s", len(f.Raw), formatBytes(f.Raw, verbose))
+	case KindDamaged:
+		s = fmt.Sprintf("damaged [%d]%s", len(f.Raw), formatBytes(f.Raw, verbose))
+	default:
+		s = f.Kind
+	}
+
+	if all := f.Candidates(); len(all) > 0 {
+		candidates := make([]string, 0, len(all))
+		for _, c := range all {
+			candidates = append(candidates, c.Type+"="+formatCandidate(c.Value, verbose))
+		}
+		s += " (" + strings.Join(candidates, " ") + ")"
+	}
+
+	return s
+}
+
+// formatBytes returns the hex of b, eliding the middle of long payloads
+// unless verbose.
+func formatBytes(r []byte, verbose bool) string {
+	var b strings.Builder
+
+	if verbose || len(r) <= 6 {
+		for i := 0; i < len(r); i++ {
+			fmt.Fprintf(&b, " %.2x", r[i])
+		}
+	} else {
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(&b, " %.2x", r[i])
+		}
+		fmt.Fprintf(&b, " ..")
+		for i := len(r) - 3; i < len(r); i++ {
+			fmt.Fprintf(&b, " %.2x", r[i])
+		}
+	}
+
+	return b.String()
+}
+
+func formatCandidate(v interface{}, verbose bool) string {
+	// values past the first maxPackedShown are elided unless verbose
+	shown := func(n int) int {
+		if !verbose && n > maxPackedShown+1 {
+			return maxPackedShown + 1
+		}
+		return n
+	}
+
+	switch v := v.(type) {
+	case float32:
+		return formatFloat(float64(v), 32)
+	case float64:
+		return formatFloat(v, 64)
+	case string:
+		return strconv.Quote(v)
+	case []uint64:
+		v = v[:shown(len(v))]
+		s := make([]string, len(v))
+		for i := range v {
+			s[i] = strconv.FormatUint(v[i], 10)
+		}
+		return formatPacked(s, verbose)
+	case []float32:
+		v = v[:shown(len(v))]
+		s := make([]string, len(v))
+		for i := range v {
+			s[i] = formatFloat(float64(v[i]), 32)
+		}
+		return formatPacked(s, verbose)
+	case []float64:
+		v = v[:shown(len(v))]
+		s := make([]string, len(v))
+		for i := range v {
+			s[i] = formatFloat(v[i], 64)
+		}
+		return formatPacked(s, verbose)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func formatFloat(f float64, bitSize int) string {
+	return strconv.FormatFloat(f, 'g', -1, bitSize)
+}
+
+func formatPacked(s []string, verbose bool) string {
+	if !verbose && len(s) > maxPackedShown {
+		s = append(s[:maxPackedShown:maxPackedShown], "..")
+	}
+	return "[" + strings.Join(s, " ") + "]"
+}
+
+// varintCandidates returns the signed, zigzag and bool interpretations of
+// the varint u.
+func varintCandidates(u uint64) []Candidate {
+	candidates := []Candidate{
+		{Type: "int64", Value: int64(u)},
+		{Type: "sint64", Value: int64(u>>1) ^ -int64(u&1)},
+	}
+	if u <= 1 {
+		candidates = append(candidates, Candidate{Type: "bool", Value: u == 1})
+	}
+	return candidates
+}
+
+// fixed32Candidates returns the float and signed interpretations of the
+// fixed32 u.
+func fixed32Candidates(u uint64) []Candidate {
+	return []Candidate{
+		{Type: "float", Value: math.Float32frombits(uint32(u))},
+		{Type: "int32", Value: int32(u)},
+	}
+}
+
+// fixed64Candidates returns the double and signed interpretations of the
+// fixed64 u.
+func fixed64Candidates(u uint64) []Candidate {
+	return []Candidate{
+		{Type: "double", Value: math.Float64frombits(u)},
+		{Type: "int64", Value: int64(u)},
+	}
+}
+
+// packedCandidates returns the packed repeated interpretations of b: a clean
+// sequence of varints and a run of fixed32 or fixed64 values. Fixed integers
+// are only offered if b is not a sequence of varints, which explains any
+// bytes whose length happens to be a multiple of 4 or 8 better.
+func packedCandidates(b []byte) []Candidate {
+	var candidates []Candidate
+
+	vs, varints := packedVarints(b)
+	if varints {
+		candidates = append(candidates, Candidate{Type: "packed varint", Value: vs})
+	}
+
+	if len(b) > 0 && len(b)%4 == 0 {
+		vs := packedFixed(b, 4)
+		if plausibleFloats(vs, func(v uint64) float64 { return float64(math.Float32frombits(uint32(v))) }) {
+			fs := make([]float32, len(vs))
+			for i, v := range vs {
+				fs[i] = math.Float32frombits(uint32(v))
+			}
+			candidates = append(candidates, Candidate{Type: "packed float", Value: fs})
+		} else if !varints {
+			candidates = append(candidates, Candidate{Type: "packed fix32", Value: vs})
+		}
+	}
+
+	if len(b) > 0 && len(b)%8 == 0 {
+		vs := packedFixed(b, 8)
+		if plausibleFloats(vs, math.Float64frombits) {
+			fs := make([]float64, len(vs))
+			for i, v := range vs {
+				fs[i] = math.Float64frombits(v)
+			}
+			candidates = append(candidates, Candidate{Type: "packed double", Value: fs})
+		} else if !varints {
+			candidates = append(candidates, Candidate{Type: "packed fix64", Value: vs})
+		}
+	}
+
+	return candidates
+}
+
+// packedVarints decodes b as a sequence of minimally encoded varints.
+func packedVarints(b []byte) ([]uint64, bool) {
+	if len(b) == 0 {
+		return nil, false
+	}
+
+	var (
+		p  = NewBuffer(b)
+		vs []uint64
+	)
+	for p.index < len(p.buf) {
+		start := p.index
+		v, err := p.DecodeVarint()
+		if err != nil {
+			return nil, false
+		}
+		// A trailing zero continuation byte means a redundant encoding
+		// which protobuf encoders never produce.
+		if p.index-start > 1 && p.buf[p.index-1] == 0 {
+			return nil, false
+		}
+		vs = append(vs, v)
+	}
+	return vs, true
+}
+
+// packedFixed decodes b as a run of little-endian values of size bytes.
+func packedFixed(b []byte, size int) []uint64 {
+	var (
+		p  = NewBuffer(b)
+		vs []uint64
+	)
+	for p.index < len(p.buf) {
+		var v uint64
+		if size == 4 {
+			v, _ = p.DecodeFixed32()
+		} else {
+			v, _ = p.DecodeFixed64()
+		}
+		vs = append(vs, v)
+	}
+	return vs
+}
+
+// plausibleFloats reports whether every value of vs converted by f is a
+// float which is likely to be stored deliberately.
+func plausibleFloats(vs []uint64, f func(uint64) float64) bool {
+	for _, v := range vs {
+		x := math.Abs(f(v))
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return false
+		}
+		if x != 0 && (x < 1e-30 || x > 1e30) {
+			return false
+		}
+	}
+	return true
+}
+
+// isMessage reports whether b parses cleanly as a protobuf message: every
+// tag is valid, every value is complete and the groups are balanced.
+func isMessage(b []byte) bool {
+	if len(b) == 0 {
+		return false
+	}
+
+	var (
+		p      = NewBuffer(b)
+		groups []uint64
+		op     uint64
+		err    error
+	)
+
+	for p.index < len(p.buf) {
+		op, err = p.DecodeVarint()
+		if err != nil {
+			return false
+		}
+		tag := op >> 3
+		if tag == 0 || tag > maxFieldNumber {
+			return false
+		}
+
+		switch op & 7 {
+		case proto.WireVarint:
+			_, err = p.DecodeVarint()
+		case proto.WireFixed64:
+			_, err = p.DecodeFixed64()
+		case proto.WireBytes:
+			_, err = p.DecodeRawBytes(false)
+		case proto.WireFixed32:
+			_, err = p.DecodeFixed32()
+		case proto.WireStartGroup:
+			groups = append(groups, tag)
+		case proto.WireEndGroup:
+			if len(groups) == 0 || groups[len(groups)-1] != tag {
+				return false
+			}
+			groups = groups[:len(groups)-1]
+		default:
+			return false
+		}
+		if err != nil {
+			return false
+		}
+	}
+
+	return len(groups) == 0
+}
+
+// isText reports whether b is non-empty printable UTF-8 text.
+func isText(b []byte) bool {
+	if len(b) == 0 || !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
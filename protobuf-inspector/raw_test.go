@@ -0,0 +1,218 @@
+package inspector
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+
+	testv1 "github.com/detailyang/pb-inspector-go/proto/go/proto/test/v1"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeWithoutSchema(t *testing.T) {
+	test := testv1.Test{
+		Int32: 150,
+		Test2: &testv1.Test2{Name: "bob"},
+	}
+
+	raw, err := proto.Marshal(&test)
+	require.Nil(t, err)
+
+	m, err := NewInspector().DecodeWithoutSchema(raw)
+	require.Nil(t, err)
+	require.Len(t, m.Fields, 2)
+
+	f := m.Fields[0]
+	require.Equal(t, 0, f.Offset)
+	require.Equal(t, 3, f.End)
+	require.Equal(t, uint64(1), f.Tag)
+	require.Equal(t, uint64(proto.WireVarint), f.Wire)
+	require.Equal(t, KindVarint, f.Kind)
+	require.Equal(t, []byte{0x96, 0x01}, f.Raw)
+	require.Equal(t, uint64(150), f.Value)
+	require.Equal(t, []Candidate{
+		{Type: "int64", Value: int64(150)},
+		{Type: "sint64", Value: int64(75)},
+	}, f.Candidates())
+
+	f = m.Fields[1]
+	require.Equal(t, 3, f.Offset)
+	require.Equal(t, 10, f.End)
+	require.Equal(t, KindMessage, f.Kind)
+	require.Len(t, f.Children, 1)
+	require.Equal(t, 5, f.Children[0].Offset)
+	require.Equal(t, 10, f.Children[0].End)
+	require.Equal(t, KindString, f.Children[0].Kind)
+	require.Equal(t, []byte("bob"), f.Children[0].Raw)
+
+	require.Equal(t, map[string]interface{}{
+		"1":  uint64(150),
+		"10": map[string]interface{}{"1": "bob"},
+	}, m.ToMap())
+
+	_, err = json.Marshal(m)
+	require.Nil(t, err)
+}
+
+func TestDecodeWithoutSchemaGroup(t *testing.T) {
+	// 1: start, 2: 1, 1: end
+	raw := []byte{0x0b, 0x10, 0x01, 0x0c}
+
+	m, err := DecodeWithoutSchema(raw)
+	require.Nil(t, err)
+	require.Len(t, m.Fields, 1)
+	require.Equal(t, KindStartGroup, m.Fields[0].Kind)
+	require.Equal(t, 4, m.Fields[0].End)
+	require.Len(t, m.Fields[0].Children, 2)
+	require.Equal(t, KindEndGroup, m.Fields[0].Children[1].Kind)
+
+	w := bytes.NewBuffer(nil)
+	m.WriteText(w, false)
+
+	o := ""
+	o += "  0: t=  1 start" + "\n"
+	o += "    1: t=  2 varint 1 (int64=1 sint64=-1 bool=true)" + "\n"
+	o += "  3: t=  1 end" + "\n"
+
+	require.Equal(t, o, w.String())
+}
+
+func TestCandidateMarshalJSON(t *testing.T) {
+	b, err := json.Marshal([]Candidate{
+		{Type: "float", Value: float32(1.5)},
+		{Type: "double", Value: math.Inf(1)},
+	})
+	require.Nil(t, err)
+	require.Equal(t, `[{"type":"float","value":1.5},{"type":"double","value":"+Inf"}]`, string(b))
+}
+
+func TestRecoverWithoutSchema(t *testing.T) {
+	raw := []byte{
+		0x08, 0x01, // 1: 1
+		0x0f, 0xaa, // wire type 7
+		0x10, 0x02, // 2: 2
+		0x1a, 0x09, 0x62, 0x6f, 0x62, // 3: truncated bytes
+		0x20, 0x03, // 4: 3
+	}
+
+	_, err := DecodeWithoutSchema(raw)
+	require.NotNil(t, err)
+
+	m := RecoverWithoutSchema(raw)
+	require.Equal(t, []Diagnostic{
+		{Offset: 2, End: 4, Kind: DiagnosticReservedWireType, Message: "reserved wire type: Buffer: [  2] t=  1 unknown wire=7"},
+		{Offset: 6, End: 11, Kind: DiagnosticDamaged, Message: "damaged: insepctor: [  6] t=  3 bytes err unexpected EOF"},
+	}, m.Diagnostics)
+
+	w := bytes.NewBuffer(nil)
+	in := NewInspector()
+	in.SetRecover(true)
+	require.Nil(t, in.InspectWithoutSchema(false, raw, w))
+
+	o := ""
+	o += "  0: t=  1 varint 1 (int64=1 sint64=-1 bool=true)" + "\n"
+	o += "  2: damaged [2] 0f aa" + "\n"
+	o += "  4: t=  2 varint 2 (int64=2 sint64=1)" + "\n"
+	o += "  6: damaged [5] 1a 09 62 6f 62" + "\n"
+	o += " 11: t=  4 varint 3 (int64=3 sint64=-2)" + "\n"
+	o += "  2: [2, 4) reserved wire type: Buffer: [  2] t=  1 unknown wire=7" + "\n"
+	o += "  6: [6, 11) damaged: insepctor: [  6] t=  3 bytes err unexpected EOF" + "\n"
+
+	require.Equal(t, o, w.String())
+}
+
+func TestDecodeWithoutSchemaGroupDiagnostics(t *testing.T) {
+	raw := []byte{
+		0x14,       // 2: unexpected end
+		0x0b,       // 1: start
+		0x13,       // 2: start
+		0x10, 0x01, // 2: 1
+		0x0c,       // 1: mismatched end of 2
+		0x1b,       // 3: start, never ended
+		0x20, 0x02, // 4: 2
+	}
+
+	m, err := DecodeWithoutSchema(raw)
+	require.Nil(t, err)
+	require.Equal(t, []Diagnostic{
+		{Offset: 0, End: 1, Kind: DiagnosticUnexpectedGroup, Message: "unexpected end group: t=2 has no start group"},
+		{Offset: 1, End: 9, Kind: DiagnosticUnclosedGroup, Message: "unclosed group: start t=1 is never ended"},
+		{Offset: 5, End: 6, Kind: DiagnosticMismatchedGroup, Message: "mismatched group: end t=1 does not match start t=2 at 2"},
+		{Offset: 6, End: 9, Kind: DiagnosticUnclosedGroup, Message: "unclosed group: start t=3 is never ended"},
+	}, m.Diagnostics)
+
+	w := bytes.NewBuffer(nil)
+	m.WriteText(w, false)
+
+	o := ""
+	o += "  0: t=  2 end" + "\n"
+	o += "  1: t=  1 start" + "\n"
+	o += "    2: t=  2 start" + "\n"
+	o += "      3: t=  2 varint 1 (int64=1 sint64=-1 bool=true)" + "\n"
+	o += "    5: t=  1 end" + "\n"
+	o += "    6: t=  3 start" + "\n"
+	o += "      7: t=  4 varint 2 (int64=2 sint64=1)" + "\n"
+	o += "  0: [0, 1) unexpected end group: t=2 has no start group" + "\n"
+	o += "  1: [1, 9) unclosed group: start t=1 is never ended" + "\n"
+	o += "  5: [5, 6) mismatched group: end t=1 does not match start t=2 at 2" + "\n"
+	o += "  6: [6, 9) unclosed group: start t=3 is never ended" + "\n"
+
+	require.Equal(t, o, w.String())
+}
+
+func TestDecodeWithoutSchemaReservedWireType(t *testing.T) {
+	m, err := DecodeWithoutSchema([]byte{0x08, 0x01, 0x0e, 0x01})
+	require.NotNil(t, err)
+	require.Len(t, m.Fields, 1)
+	require.Equal(t, []Diagnostic{
+		{Offset: 2, End: 4, Kind: DiagnosticReservedWireType, Message: "reserved wire type: Buffer: [  2] t=  1 unknown wire=6"},
+	}, m.Diagnostics)
+}
+
+func TestDecodeWithoutSchemaDeepGroups(t *testing.T) {
+	// start-group tags only, which used to overflow the stack
+	raw := bytes.Repeat([]byte{0x0b}, 3<<20)
+
+	m, err := DecodeWithoutSchema(raw)
+	require.NotNil(t, err)
+	require.Equal(t, Diagnostic{
+		Offset:  maxDepth,
+		End:     len(raw),
+		Kind:    DiagnosticTooDeep,
+		Message: "too deep: insepctor: [100] t=  1 groups nested deeper than 100",
+	}, m.Diagnostics[0])
+
+	m = RecoverWithoutSchema(raw)
+	require.Equal(t, Diagnostic{
+		Offset:  maxDepth,
+		End:     len(raw),
+		Kind:    DiagnosticTooDeep,
+		Message: "too deep: insepctor: [100] t=  1 groups nested deeper than 100",
+	}, m.Diagnostics[maxDepth])
+
+	require.NotNil(t, NewInspector().InspectWithoutSchema(false, raw, bytes.NewBuffer(nil)))
+}
+
+func TestDecodeWithoutSchemaDeepMessages(t *testing.T) {
+	raw := []byte{0x08, 0x01}
+	for i := 0; i <= maxDepth; i++ {
+		raw = append(appendVarint([]byte{0x0a}, uint64(len(raw)), 0), raw...)
+	}
+
+	m, err := DecodeWithoutSchema(raw)
+	require.Nil(t, err)
+	f := m.Fields[0]
+	for i := 0; i < maxDepth; i++ {
+		require.Equal(t, KindMessage, f.Kind, i)
+		f = f.Children[0]
+	}
+	// payloads nested deeper are left as bytes
+	require.Equal(t, KindBytes, f.Kind)
+	require.Nil(t, f.Children)
+
+	b, err := Assemble(Disassemble(raw))
+	require.Nil(t, err)
+	require.Equal(t, raw, b)
+}
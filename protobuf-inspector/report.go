@@ -0,0 +1,182 @@
+package inspector
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+// ReportNode is a node of the field tree rendered by WriteHTMLReport.
+// Start and End are the byte range [Start, End) of the node in the message.
+type ReportNode struct {
+	Label    string
+	Value    string
+	Start    int
+	End      int
+	Children []*ReportNode
+}
+
+// ReportNodesFromSpans converts the spans of a Decoder to report nodes
+func ReportNodesFromSpans(spans []*Span) []*ReportNode {
+	nodes := make([]*ReportNode, 0, len(spans))
+	for _, s := range spans {
+		n := &ReportNode{
+			Label: fmt.Sprintf("%s (t=%d %s)", s.Name, s.Tag, wireName(s.Wire)),
+			Start: s.Start,
+			End:   s.End,
+		}
+		if len(s.Children) > 0 {
+			n.Children = ReportNodesFromSpans(s.Children)
+		} else {
+			values := make([]string, 0, len(s.Values))
+			for _, v := range s.Values {
+				if b, ok := v.([]byte); ok {
+					values = append(values, fmt.Sprintf("% x", b))
+				} else if s, ok := v.(InvalidUTF8); ok {
+					values = append(values, fmt.Sprintf("%s (invalid utf-8)", s))
+				} else {
+					values = append(values, fmt.Sprintf("%v", v))
+				}
+			}
+			n.Value = strings.Join(values, ", ")
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// ReportNodesFromRawFields converts the fields decoded without schema to
+// report nodes
+func ReportNodesFromRawFields(fields []*RawField) []*ReportNode {
+	nodes := make([]*ReportNode, 0, len(fields))
+	for _, f := range fields {
+		nodes = append(nodes, &ReportNode{
+			Label:    fmt.Sprintf("t=%d", f.Tag),
+			Value:    f.Text(false),
+			Start:    f.Offset,
+			End:      f.End,
+			Children: ReportNodesFromRawFields(f.Children),
+		})
+	}
+	return nodes
+}
+
+func wireName(wire uint64) string {
+	switch wire {
+	case proto.WireVarint:
+		return "varint"
+	case proto.WireFixed64:
+		return "fix64"
+	case proto.WireBytes:
+		return "bytes"
+	case proto.WireStartGroup:
+		return "start"
+	case proto.WireEndGroup:
+		return "end"
+	case proto.WireFixed32:
+		return "fix32"
+	}
+	return fmt.Sprintf("wire=%d", wire)
+}
+
+type reportByte struct {
+	Offset int
+	Hex    string
+}
+
+type reportRow struct {
+	Offset int
+	Bytes  []reportByte
+}
+
+type reportPage struct {
+	Title string
+	Size  int
+	Nodes []*ReportNode
+	Rows  []reportRow
+}
+
+// WriteHTMLReport writes a self-contained HTML page which shows nodes as a
+// collapsible tree next to the hex bytes of raw, highlighting the bytes of
+// the hovered node.
+func WriteHTMLReport(w io.Writer, title string, raw []byte, nodes []*ReportNode) error {
+	page := reportPage{
+		Title: title,
+		Size:  len(raw),
+		Nodes: nodes,
+	}
+	for i, b := range raw {
+		if i%16 == 0 {
+			page.Rows = append(page.Rows, reportRow{Offset: i})
+		}
+		row := &page.Rows[len(page.Rows)-1]
+		row.Bytes = append(row.Bytes, reportByte{Offset: i, Hex: fmt.Sprintf("%.2x", b)})
+	}
+	return reportTemplate.Execute(w, page)
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { margin: 0; font: 13px/1.5 Menlo, Consolas, monospace; color: #222; }
+header { padding: 8px 16px; background: #2d3e50; color: #fff; }
+main { display: flex; height: calc(100vh - 40px); }
+#tree, #hex { overflow: auto; padding: 8px 16px; }
+#tree { flex: 1; border-right: 1px solid #ddd; }
+#hex { flex: 1; }
+details { margin-left: 16px; }
+summary, .leaf { cursor: default; white-space: nowrap; }
+.leaf { margin-left: 16px; padding-left: 14px; }
+.range { color: #999; }
+.value { color: #0a6e31; }
+.row { white-space: nowrap; }
+.offset { color: #999; display: inline-block; width: 64px; }
+.b { display: inline-block; width: 24px; }
+.b.hl { background: #ffe58a; }
+.hover { background: #eef3ff; }
+</style>
+</head>
+<body>
+<header>{{.Title}} ({{.Size}} bytes)</header>
+<main>
+<div id="tree">
+{{range .Nodes}}{{template "node" .}}{{end}}
+</div>
+<div id="hex">
+{{range .Rows}}<div class="row"><span class="offset">{{printf "%06x" .Offset}}</span>{{range .Bytes}}<span class="b" id="b{{.Offset}}">{{.Hex}}</span>{{end}}</div>
+{{end}}
+</div>
+</main>
+<script>
+(function () {
+  function mark(el, on) {
+    var start = +el.getAttribute("data-start"), end = +el.getAttribute("data-end");
+    for (var i = start; i < end; i++) {
+      var b = document.getElementById("b" + i);
+      if (b) b.classList.toggle("hl", on);
+    }
+    el.classList.toggle("hover", on);
+    if (on) {
+      var first = document.getElementById("b" + start);
+      if (first && first.scrollIntoViewIfNeeded) first.scrollIntoViewIfNeeded();
+    }
+  }
+  var nodes = document.querySelectorAll("[data-start]");
+  for (var i = 0; i < nodes.length; i++) {
+    nodes[i].addEventListener("mouseover", function (e) { e.stopPropagation(); mark(this, true); });
+    nodes[i].addEventListener("mouseout", function (e) { e.stopPropagation(); mark(this, false); });
+  }
+})();
+</script>
+</body>
+</html>
+{{define "node"}}{{if .Children}}<details open><summary data-start="{{.Start}}" data-end="{{.End}}">{{.Label}} <span class="range">[{{.Start}}, {{.End}})</span>{{if .Value}} <span class="value">{{.Value}}</span>{{end}}</summary>
+{{range .Children}}{{template "node" .}}{{end}}</details>
+{{else}}<div class="leaf" data-start="{{.Start}}" data-end="{{.End}}">{{.Label}} <span class="range">[{{.Start}}, {{.End}})</span> <span class="value">{{.Value}}</span></div>
+{{end}}{{end}}`))
@@ -0,0 +1,509 @@
+package inspector
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gogo/protobuf/proto"
+)
+
+// The language of Assemble and Disassemble is modeled on protoscope:
+//
+//	1: 150          field 1 varint, or 5z zigzag, 5i32 fixed32, 5i64 fixed64,
+//	                1.5 double, 1.5i32 float, true and false
+//	2: {"hello"}    field 2 length-delimited, {} prefixes the length of its contents
+//	3: { 1: 5i32 }  field 3 embedded message
+//	4: !{ 1: 1 }    field 4 group, closed by its end group tag
+//	5:I64 `0a05`    field 5 with an explicit wire type (VARINT, I64, LEN, SGROUP,
+//	                EGROUP, I32 or 0-7) followed by raw bytes in hex
+//	long-form:2 1   varint padded with 2 redundant bytes
+//	# comment
+//
+// Quoted strings and hex literals emit their bytes as is, anywhere.
+
+// The kinds of scope tokens besides braces
+const (
+	scopeEOF = -(iota + 1)
+	scopeWord
+	scopeBytes
+	scopeGroup // !{
+)
+
+var (
+	scopeTag   = regexp.MustCompile(`^([0-9]+):([A-Z0-9]*)$`)
+	scopeWires = map[string]uint64{
+		"VARINT": proto.WireVarint,
+		"I64":    proto.WireFixed64,
+		"LEN":    proto.WireBytes,
+		"SGROUP": proto.WireStartGroup,
+		"EGROUP": proto.WireEndGroup,
+		"I32":    proto.WireFixed32,
+	}
+)
+
+type scopeToken struct {
+	kind   rune
+	text   string // the word, or the bytes of quoted strings and hex literals
+	line   int
+	column int
+}
+
+// scopeFrame is an open { or !{
+type scopeFrame struct {
+	tok   scopeToken
+	start int
+	pad   int
+	group uint64 // field number of !{
+}
+
+// Assemble assembles src, written in the protoscope-like language of
+// Disassemble, to protobuf binary data. Errors are TextErrors pointing to
+// the offending input.
+func Assemble(src string) ([]byte, error) {
+	toks, err := lexScope(src)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		out    []byte
+		stack  []scopeFrame
+		pad    int
+		padTok scopeToken
+	)
+	errorf := func(t scopeToken, format string, args ...interface{}) error {
+		return &TextError{Line: t.line, Column: t.column, Err: fmt.Errorf(format, args...)}
+	}
+
+	for i := 0; ; i++ {
+		t := toks[i]
+
+		// long-form only pads varints: tags, varint values and lengths
+		if pad > 0 && t.kind != '{' && t.kind != scopeWord {
+			return nil, errorf(padTok, "long-form must precede a varint")
+		}
+
+		switch t.kind {
+		case scopeEOF:
+			if len(stack) > 0 {
+				return nil, errorf(stack[len(stack)-1].tok, "unclosed %s", stack[len(stack)-1].tok.text)
+			}
+			return out, nil
+
+		case scopeBytes:
+			out = append(out, t.text...)
+
+		case scopeGroup:
+			return nil, errorf(t, "group without field number")
+
+		case '{':
+			stack = append(stack, scopeFrame{tok: t, start: len(out), pad: pad})
+			pad = 0
+
+		case '}':
+			if len(stack) == 0 {
+				return nil, errorf(t, "unexpected }")
+			}
+			f := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if f.tok.kind == scopeGroup {
+				out = appendVarint(out, f.group<<3|proto.WireEndGroup, 0)
+				break
+			}
+			content := append([]byte(nil), out[f.start:]...)
+			out = appendVarint(out[:f.start], uint64(len(content)), f.pad)
+			out = append(out, content...)
+
+		case scopeWord:
+			if strings.HasPrefix(t.text, "long-form:") {
+				n, err := strconv.Atoi(strings.TrimPrefix(t.text, "long-form:"))
+				if err != nil || n < 1 || n > 9 {
+					return nil, errorf(t, "invalid %s", t.text)
+				}
+				if pad > 0 {
+					return nil, errorf(padTok, "long-form must precede a varint")
+				}
+				pad, padTok = n, t
+				continue
+			}
+
+			if m := scopeTag.FindStringSubmatch(t.text); m != nil {
+				number, err := strconv.ParseUint(m[1], 10, 61)
+				if err != nil {
+					return nil, errorf(t, "invalid field number %s", m[1])
+				}
+				wire, ok := scopeWires[m[2]]
+				if len(m[2]) == 1 && m[2][0] >= '0' && m[2][0] <= '7' {
+					wire, ok = uint64(m[2][0]-'0'), true
+				}
+				if m[2] == "" {
+					wire, ok = inferScopeWire(toks[i+1:])
+				}
+				if !ok {
+					if v := toks[i+1]; v.kind == scopeWord && m[2] == "" {
+						if _, _, err := parseScopeValue(v.text); err != nil {
+							return nil, errorf(v, "%v", err)
+						}
+					}
+					return nil, errorf(t, "cannot infer the wire type of field %d", number)
+				}
+
+				out = appendVarint(out, number<<3|wire, pad)
+				pad = 0
+				if m[2] == "" && wire == proto.WireStartGroup && toks[i+1].kind == scopeGroup {
+					i++
+					stack = append(stack, scopeFrame{tok: toks[i], group: number})
+				}
+				break
+			}
+
+			v, wire, err := parseScopeValue(t.text)
+			if err != nil {
+				return nil, errorf(t, "%v", err)
+			}
+			switch wire {
+			case proto.WireVarint:
+				out = appendVarint(out, v, pad)
+				pad = 0
+			case proto.WireFixed32:
+				out = append(out, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+			default:
+				for j := uint(0); j < 64; j += 8 {
+					out = append(out, byte(v>>j))
+				}
+			}
+			if pad > 0 {
+				return nil, errorf(padTok, "long-form must precede a varint")
+			}
+		}
+	}
+}
+
+// inferScopeWire infers the wire type of a field from the tokens of its value
+func inferScopeWire(toks []scopeToken) (uint64, bool) {
+	t := toks[0]
+	if t.kind == scopeWord && strings.HasPrefix(t.text, "long-form:") {
+		t = toks[1]
+	}
+
+	switch t.kind {
+	case '{':
+		return proto.WireBytes, true
+	case scopeGroup:
+		return proto.WireStartGroup, true
+	case scopeWord:
+		if _, wire, err := parseScopeValue(t.text); err == nil {
+			return wire, true
+		}
+	}
+	return 0, false
+}
+
+// parseScopeValue parses a number or bool to its bits and wire type
+func parseScopeValue(s string) (uint64, uint64, error) {
+	switch s {
+	case "true":
+		return 1, proto.WireVarint, nil
+	case "false":
+		return 0, proto.WireVarint, nil
+	}
+
+	suffix := ""
+	for _, each := range []string{"i32", "i64", "z"} {
+		if strings.HasSuffix(s, each) && len(s) > len(each) {
+			suffix = each
+			s = strings.TrimSuffix(s, each)
+			break
+		}
+	}
+
+	digits := strings.TrimPrefix(s, "-")
+	isHex := strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X")
+	isFloat := !isHex && (strings.ContainsAny(digits, ".eE") || digits == "inf" || digits == "nan")
+
+	bits := 64
+	if suffix == "i32" {
+		bits = 32
+	}
+
+	if isFloat {
+		if suffix == "z" {
+			return 0, 0, fmt.Errorf("invalid value %sz", s)
+		}
+		f, err := strconv.ParseFloat(s, bits)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid float %s", s)
+		}
+		if bits == 32 {
+			return uint64(math.Float32bits(float32(f))), proto.WireFixed32, nil
+		}
+		return math.Float64bits(f), proto.WireFixed64, nil
+	}
+
+	var (
+		v   uint64
+		err error
+	)
+	if strings.HasPrefix(s, "-") || suffix == "z" {
+		var n int64
+		n, err = strconv.ParseInt(s, 0, bits)
+		v = uint64(n)
+		if suffix == "z" {
+			v = uint64(n<<1) ^ uint64(n>>63)
+		} else if bits == 32 {
+			v = uint64(uint32(n))
+		}
+	} else {
+		v, err = strconv.ParseUint(s, 0, bits)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid value %s", s+suffix)
+	}
+
+	switch suffix {
+	case "i32":
+		return v, proto.WireFixed32, nil
+	case "i64":
+		return v, proto.WireFixed64, nil
+	}
+	return v, proto.WireVarint, nil
+}
+
+// appendVarint appends the varint v padded with pad redundant bytes
+func appendVarint(b []byte, v uint64, pad int) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	b = append(b, byte(v))
+	if pad > 0 {
+		b[len(b)-1] |= 0x80
+		for i := 1; i < pad; i++ {
+			b = append(b, 0x80)
+		}
+		b = append(b, 0)
+	}
+	return b
+}
+
+func lexScope(s string) ([]scopeToken, error) {
+	var (
+		toks      []scopeToken
+		line, col = 1, 1
+		i         int
+	)
+	advance := func(n int) {
+		for _, r := range s[i : i+n] {
+			if r == '\n' {
+				line++
+				col = 1
+			} else {
+				col++
+			}
+		}
+		i += n
+	}
+
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\v' || c == '\f':
+			advance(1)
+
+		case c == '#':
+			n := strings.IndexByte(s[i:], '\n')
+			if n < 0 {
+				n = len(s) - i
+			}
+			advance(n)
+
+		case c == '{' || c == '}':
+			toks = append(toks, scopeToken{kind: rune(c), text: string(c), line: line, column: col})
+			advance(1)
+
+		case c == '!' && strings.HasPrefix(s[i:], "!{"):
+			toks = append(toks, scopeToken{kind: scopeGroup, text: "!{", line: line, column: col})
+			advance(2)
+
+		case c == '"' || c == '\'':
+			text, n, err := unquoteText(s[i:])
+			if err != nil {
+				return nil, &TextError{Line: line, Column: col, Err: err}
+			}
+			toks = append(toks, scopeToken{kind: scopeBytes, text: text, line: line, column: col})
+			advance(n)
+
+		case c == '`':
+			n := strings.IndexByte(s[i+1:], '`')
+			if n < 0 {
+				return nil, &TextError{Line: line, Column: col, Err: fmt.Errorf("unterminated hex")}
+			}
+			b, err := hex.DecodeString(strings.Join(strings.Fields(s[i+1:i+1+n]), ""))
+			if err != nil {
+				return nil, &TextError{Line: line, Column: col, Err: fmt.Errorf("invalid hex %v", err)}
+			}
+			toks = append(toks, scopeToken{kind: scopeBytes, text: string(b), line: line, column: col})
+			advance(n + 2)
+
+		default:
+			n := strings.IndexFunc(s[i:], func(r rune) bool {
+				return unicode.IsSpace(r) || strings.ContainsRune("{}\"'`#", r)
+			})
+			if n < 0 {
+				n = len(s) - i
+			}
+			toks = append(toks, scopeToken{kind: scopeWord, text: s[i : i+n], line: line, column: col})
+			advance(n)
+		}
+	}
+
+	toks = append(toks, scopeToken{kind: scopeEOF, text: "EOF", line: line, column: col})
+	return toks, nil
+}
+
+// Disassemble writes raw protobuf binary data in a protoscope-like language
+// which Assemble turns back into the same bytes: non-canonical varints are
+// kept as long-form and bytes which are not well-formed as hex.
+func Disassemble(raw []byte) string {
+	var b strings.Builder
+	disassemble(&b, raw, 0)
+	return b.String()
+}
+
+func disassemble(w *strings.Builder, raw []byte, depth int) {
+	line := func(format string, args ...interface{}) {
+		w.WriteString(strings.Repeat("  ", depth))
+		fmt.Fprintf(w, format, args...)
+		w.WriteString("\n")
+	}
+
+	for i := 0; i < len(raw); {
+		start := i
+		tag, n, tagForm, ok := scopeVarint(raw[i:])
+		if !ok {
+			line("`%x`", raw[start:])
+			return
+		}
+		i += n
+		number := tag >> 3
+
+		switch tag & 7 {
+		case proto.WireVarint:
+			v, m, form, ok := scopeVarint(raw[i:])
+			if !ok {
+				break
+			}
+			i += m
+			if int64(v) < 0 {
+				line("%s%d: %s%d", tagForm, number, form, int64(v))
+			} else {
+				line("%s%d: %s%d", tagForm, number, form, v)
+			}
+			continue
+
+		case proto.WireFixed64:
+			if len(raw)-i < 8 {
+				break
+			}
+			v := uint64(0)
+			for j := uint(0); j < 8; j++ {
+				v |= uint64(raw[i+int(j)]) << (8 * j)
+			}
+			i += 8
+			if plausibleFloats([]uint64{v}, math.Float64frombits) {
+				line("%s%d: %s", tagForm, number, scopeFloat(formatFloat(math.Float64frombits(v), 64)))
+			} else {
+				line("%s%d: %di64", tagForm, number, v)
+			}
+			continue
+
+		case proto.WireFixed32:
+			if len(raw)-i < 4 {
+				break
+			}
+			v := uint64(raw[i]) | uint64(raw[i+1])<<8 | uint64(raw[i+2])<<16 | uint64(raw[i+3])<<24
+			i += 4
+			f32 := func(v uint64) float64 { return float64(math.Float32frombits(uint32(v))) }
+			if plausibleFloats([]uint64{v}, f32) {
+				line("%s%d: %si32", tagForm, number, scopeFloat(formatFloat(f32(v), 32)))
+			} else {
+				line("%s%d: %di32", tagForm, number, v)
+			}
+			continue
+
+		case proto.WireBytes:
+			l, m, form, ok := scopeVarint(raw[i:])
+			if !ok || l > uint64(len(raw)-i-m) {
+				break
+			}
+			i += m
+			p := raw[i : i+int(l)]
+			i += int(l)
+			switch {
+			case len(p) == 0:
+				line("%s%d: %s{}", tagForm, number, form)
+			case depth < maxDepth && isMessage(p) && !isText(p):
+				line("%s%d: %s{", tagForm, number, form)
+				disassemble(w, p, depth+1)
+				line("}")
+			case isText(p):
+				line("%s%d: %s{%s}", tagForm, number, form, strconv.Quote(string(p)))
+			default:
+				line("%s%d: %s{`%x`}", tagForm, number, form, p)
+			}
+			continue
+
+		case proto.WireStartGroup:
+			line("%s%d:SGROUP", tagForm, number)
+			depth++
+			continue
+
+		case proto.WireEndGroup:
+			if depth > 0 {
+				depth--
+			}
+			line("%s%d:EGROUP", tagForm, number)
+			continue
+		}
+
+		// the value is malformed or of a reserved wire type
+		line("`%x`", raw[start:])
+		return
+	}
+}
+
+// scopeVarint decodes the varint which b starts with, returning its length
+// and the long-form prefix which reproduces it. ok is false if the varint is
+// malformed or cannot be reproduced.
+func scopeVarint(b []byte) (v uint64, n int, form string, ok bool) {
+	p := NewBuffer(b)
+	v, err := p.DecodeVarint()
+	if err != nil {
+		return 0, 0, "", false
+	}
+	n = p.index
+
+	canonical := len(appendVarint(nil, v, 0))
+	if pad := n - canonical; pad > 0 {
+		form = fmt.Sprintf("long-form:%d ", pad)
+		if string(appendVarint(nil, v, pad)) != string(b[:n]) {
+			return 0, 0, "", false
+		}
+	} else if string(appendVarint(nil, v, 0)) != string(b[:n]) {
+		return 0, 0, "", false
+	}
+	return v, n, form, true
+}
+
+// scopeFloat makes sure the formatted float f is parsed as a float
+func scopeFloat(f string) string {
+	if !strings.ContainsAny(f, ".eE") {
+		return f + ".0"
+	}
+	return f
+}
@@ -0,0 +1,110 @@
+package inspector
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssemble(t *testing.T) {
+	raw, err := Assemble(`
+1: 150
+2: {"hello"}
+3: { 1: 5i32 }  # nested
+4: !{ 1: -1z }
+5:I64 ` + "`0a05`" + `
+6: 1.5 7: 1.5i32 8: true
+long-form:1 1: long-form:2 0
+2: long-form:1 {}
+`)
+	require.Nil(t, err)
+	require.Equal(t, []byte{
+		0x08, 0x96, 0x01,
+		0x12, 0x05, 'h', 'e', 'l', 'l', 'o',
+		0x1a, 0x05, 0x0d, 0x05, 0x00, 0x00, 0x00,
+		0x23, 0x08, 0x01, 0x24,
+		0x29, 0x0a, 0x05,
+		0x31, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x3f,
+		0x3d, 0x00, 0x00, 0xc0, 0x3f,
+		0x40, 0x01,
+		0x88, 0x00, 0x80, 0x80, 0x00,
+		0x12, 0x80, 0x00,
+	}, raw)
+
+	tests := []struct {
+		src string
+		err string
+	}{
+		{"1: {", "inspector: 1:4: unclosed {"},
+		{"1: \"x\"", "inspector: 1:1: cannot infer the wire type of field 1"},
+		{"1: 5x", "inspector: 1:4: invalid value 5x"},
+		{"long-form:2 1i32", "inspector: 1:1: long-form must precede a varint"},
+		{"}", "inspector: 1:1: unexpected }"},
+	}
+	for _, test := range tests {
+		_, err := Assemble(test.src)
+		require.NotNil(t, err, test.src)
+		require.Equal(t, test.err, err.Error())
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	raw := []byte{
+		0x08, 0x96, 0x01,
+		0x10, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01,
+		0x1a, 0x03, 0x08, 0x80, 0x00,
+		0x22, 0x05, 'h', 'e', 'l', 'l', 'o',
+		0x2b, 0x08, 0x01, 0x2c,
+		0x31, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xf8, 0x3f,
+		0x3d, 0xff, 0xff, 0xff, 0xff,
+		0x42, 0x02, 0xff, 0x00,
+		0x4e, 0x01,
+	}
+
+	o := ""
+	o += "1: 150\n"
+	o += "2: -1\n"
+	o += "3: {\n"
+	o += "  1: long-form:1 0\n"
+	o += "}\n"
+	o += "4: {\"hello\"}\n"
+	o += "5:SGROUP\n"
+	o += "  1: 1\n"
+	o += "5:EGROUP\n"
+	o += "6: 1.5\n"
+	o += "7: 4294967295i32\n"
+	o += "8: {`ff00`}\n"
+	o += "`4e01`\n"
+
+	text := Disassemble(raw)
+	require.Equal(t, o, text)
+
+	b, err := Assemble(text)
+	require.Nil(t, err)
+	require.Equal(t, raw, b)
+
+	w := bytes.NewBuffer(nil)
+	in := NewInspector()
+	in.SetDisassemble(true)
+	require.Nil(t, in.InspectWithoutSchema(false, raw, w))
+	require.Equal(t, o, w.String())
+}
+
+func TestDisassembleRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		raw := make([]byte, r.Intn(32))
+		r.Read(raw)
+		// make well-formed prefixes more likely
+		if len(raw) > 2 && i%2 == 0 {
+			raw[0] = byte(r.Intn(16)<<3 | []int{0, 1, 2, 5}[r.Intn(4)])
+			raw[1] &= 0x0f
+		}
+
+		b, err := Assemble(Disassemble(raw))
+		require.Nil(t, err, "%x", raw)
+		require.Equal(t, raw, append([]byte{}, b...), "%x", raw)
+	}
+}
@@ -0,0 +1,188 @@
+package inspector
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	pp "github.com/emicklei/proto"
+)
+
+// Selection is a value selected from a decoded message and its path, like
+// items[1].id for the path items[*].id
+type Selection struct {
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// Select returns the values of a message m decoded without schema, keyed
+// by tag numbers, at path like 10.1 or 10[*].1, in field order. The fields
+// which are not in m select nothing.
+func Select(m map[string]interface{}, path string) ([]Selection, error) {
+	segs, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	sl := &selector{}
+	if err := sl.message("", nil, m, segs, ""); err != nil {
+		return nil, err
+	}
+	return sl.selections, nil
+}
+
+// SelectWithDefinition returns the values of m, a message pkg.name decoded
+// by d, at path like test2.name, items[*].id or attrs["k"], in field order.
+// Map fields are told from embedded messages by d.
+func SelectWithDefinition(d *Definition, pkg, name string, m map[string]interface{}, path string) ([]Selection, error) {
+	msg, ok := d.Message(pkg, name)
+	if !ok {
+		return nil, fmt.Errorf("no definition found for package [%s] type [%s]", pkg, name)
+	}
+	segs, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	sl := &selector{d: d}
+	if err := sl.message(pkg, msg, m, segs, ""); err != nil {
+		return nil, err
+	}
+	return sl.selections, nil
+}
+
+// WriteSelections writes selections as text lines to w
+func WriteSelections(w io.Writer, selections []Selection) {
+	for _, s := range selections {
+		fmt.Fprintf(w, "%s: %s\n", s.Path, formatValue(s.Value))
+	}
+}
+
+type selector struct {
+	d          *Definition // nil without schema
+	selections []Selection
+}
+
+// message selects segs from obj, a message msg of package pkg or a message
+// without schema if msg is nil
+func (sl *selector) message(pkg string, msg *pp.Message, obj map[string]interface{}, segs []pathSegment, path string) error {
+	seg := segs[0]
+	v, ok := obj[seg.name]
+	if !ok {
+		return nil
+	}
+
+	var f *fieldInfo
+	if msg != nil {
+		for _, each := range messageFields(msg) {
+			if each.name == seg.name {
+				f = each
+				break
+			}
+		}
+	}
+
+	type element struct {
+		path  string
+		value interface{}
+	}
+	var elements []element
+
+	list, isList := v.([]interface{})
+	// only the schema has map fields, messages without it are keyed by tag
+	// numbers like the maps keyed by integers
+	entries, isMap := v.(map[string]interface{})
+	isMap = isMap && f != nil && f.keyType != ""
+	switch seg.selector {
+	case selectNone:
+		elements = append(elements, element{joinPath(path, seg.name), v})
+
+	case selectIndex:
+		if isMap {
+			// the keys of maps which are not keyed by strings
+			if k, ok := findMapKey(entries, seg.key); ok {
+				elements = append(elements, element{joinPath(path, mapKeySegment(seg.name, k)), entries[k]})
+			}
+			break
+		}
+		if !isList {
+			// a field which occurs once is not a list without schema
+			list = []interface{}{v}
+		}
+		if seg.index < len(list) {
+			elements = append(elements, element{joinPath(path, seg.String()), list[seg.index]})
+		}
+
+	case selectKey:
+		if !isMap {
+			return fmt.Errorf("inspector: %s is not a map", joinPath(path, seg.name))
+		}
+		if k, ok := findMapKey(entries, seg.key); ok {
+			elements = append(elements, element{joinPath(path, mapKeySegment(seg.name, k)), entries[k]})
+		}
+
+	case selectAll:
+		switch {
+		case isList:
+			for i, each := range list {
+				p := joinPath(path, pathSegment{name: seg.name, selector: selectIndex, index: i}.String())
+				elements = append(elements, element{p, each})
+			}
+		case isMap:
+			var keys []string
+			for k := range entries {
+				keys = append(keys, k)
+			}
+			sort.Slice(keys, func(i, j int) bool { return lessMapKey(keys[i], keys[j]) })
+			for _, k := range keys {
+				elements = append(elements, element{joinPath(path, mapKeySegment(seg.name, k)), entries[k]})
+			}
+		default:
+			elements = append(elements, element{joinPath(path, pathSegment{name: seg.name, selector: selectIndex}.String()), v})
+		}
+	}
+
+	// the message type of the elements
+	var sub *pp.Message
+	subPkg := pkg
+	if sl.d != nil && f != nil {
+		if mpkg, m, ok := sl.d.resolveMessage(pkg, f.typ); ok {
+			subPkg, sub = mpkg, m
+		}
+	}
+
+	for _, e := range elements {
+		if len(segs) == 1 {
+			sl.selections = append(sl.selections, Selection{Path: e.path, Value: e.value})
+			continue
+		}
+		m, ok := e.value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("inspector: %s is not a message", e.path)
+		}
+		if err := sl.message(subPkg, sub, m, segs[1:], e.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findMapKey returns the key of entries which is k, or k formatted by the
+// decoder like "1 (int32)" for the maps which are not keyed by strings
+func findMapKey(entries map[string]interface{}, k string) (string, bool) {
+	if _, ok := entries[k]; ok {
+		return k, true
+	}
+	for each := range entries {
+		if i := strings.LastIndex(each, " ("); i > 0 && strings.HasSuffix(each, ")") {
+			key := each[:i]
+			if unquoted, err := strconv.Unquote(key); err == nil {
+				key = unquoted
+			}
+			if key == k {
+				return each, true
+			}
+		}
+	}
+	return "", false
+}
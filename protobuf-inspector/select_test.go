@@ -0,0 +1,118 @@
+package inspector
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	testv1 "github.com/detailyang/pb-inspector-go/proto/go/proto/test/v1"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelect(t *testing.T) {
+	schema := `
+syntax = "proto3";
+
+package envelope.v1;
+
+message Envelope {
+  string name = 1;
+  repeated Item items = 2;
+  map<string, Item> attrs = 3;
+  map<int32, string> codes = 4;
+  Item item = 5;
+}
+
+message Item {
+  int32 id = 1;
+  repeated string tags = 2;
+}
+`
+	in := NewInspector()
+	require.Nil(t, in.ReadSchemaFromReader("envelope.proto", strings.NewReader(schema)))
+
+	raw, err := in.EncodeJSON("envelope.v1", "Envelope", []byte(`{
+  "name": "a",
+  "items": [{"id": 1, "tags": ["x", "y"]}, {"id": 2}],
+  "attrs": {"k": {"id": 3}, "l": {"id": 4}},
+  "codes": {"7": "seven"},
+  "item": {"id": 5}
+}`))
+	require.Nil(t, err)
+
+	tests := []struct {
+		path       string
+		selections []Selection
+	}{
+		{"name", []Selection{{"name", "a"}}},
+		{"item.id", []Selection{{"item.id", int32(5)}}},
+		{"items[*].id", []Selection{{"items[0].id", int32(1)}, {"items[1].id", int32(2)}}},
+		{"items[0].tags[1]", []Selection{{"items[0].tags[1]", "y"}}},
+		{"items[*].tags[*]", []Selection{{"items[0].tags[0]", "x"}, {"items[0].tags[1]", "y"}}},
+		{"items[5].id", nil},
+		{`attrs["k"].id`, []Selection{{`attrs["k"].id`, int32(3)}}},
+		{"attrs[*].id", []Selection{{`attrs["k"].id`, int32(3)}, {`attrs["l"].id`, int32(4)}}},
+		{"codes[7]", []Selection{{"codes[7]", "seven"}}},
+		{"missing.id", nil},
+	}
+	for _, test := range tests {
+		selections, err := in.SelectWithSchema("envelope.v1", "Envelope", raw, test.path)
+		require.Nil(t, err, test.path)
+		require.Equal(t, test.selections, selections, test.path)
+	}
+
+	_, err = in.SelectWithSchema("envelope.v1", "Envelope", raw, "name.id")
+	require.Equal(t, "inspector: name is not a message", err.Error())
+	_, err = in.SelectWithSchema("envelope.v1", "Envelope", raw, `name["k"]`)
+	require.Equal(t, "inspector: name is not a map", err.Error())
+	_, err = in.SelectWithSchema("envelope.v1", "Envelope", raw, "items[")
+	require.Equal(t, "inspector: unclosed [ in path items[", err.Error())
+
+	selections, err := in.SelectWithSchema("envelope.v1", "Envelope", raw, "items[*]")
+	require.Nil(t, err)
+	w := bytes.NewBuffer(nil)
+	WriteSelections(w, selections)
+	require.Equal(t, `items[0]: {"id":1,"tags":["x","y"]}`+"\n"+`items[1]: {"id":2}`+"\n", w.String())
+}
+
+func TestSelectNumericMapKeys(t *testing.T) {
+	schema := `
+syntax = "proto3";
+
+package envelope.v1;
+
+message Envelope {
+  map<string, string> attrs = 1;
+}
+`
+	in := NewInspector()
+	require.Nil(t, in.ReadSchemaFromReader("envelope.proto", strings.NewReader(schema)))
+
+	raw, err := in.EncodeJSON("envelope.v1", "Envelope", []byte(`{"attrs": {"1": "a", "2": "b"}}`))
+	require.Nil(t, err)
+
+	// a map keyed by numbers is no message keyed by tag numbers
+	selections, err := in.SelectWithSchema("envelope.v1", "Envelope", raw, `attrs["1"]`, "attrs[*]")
+	require.Nil(t, err)
+	require.Equal(t, []Selection{
+		{`attrs["1"]`, "a"},
+		{`attrs["1"]`, "a"},
+		{`attrs["2"]`, "b"},
+	}, selections)
+}
+
+func TestSelectWithoutSchema(t *testing.T) {
+	raw, err := proto.Marshal(&testv1.Test{Int32: 1, Test2: &testv1.Test2{Name: "bob"}})
+	require.Nil(t, err)
+
+	in := NewInspector()
+	selections, err := in.SelectWithoutSchema(raw, "10.1")
+	require.Nil(t, err)
+	require.Equal(t, []Selection{{"10.1", "bob"}}, selections)
+
+	// an embedded message which occurs once is a list of one
+	selections, err = in.SelectWithoutSchema(raw, "10[*].1")
+	require.Nil(t, err)
+	require.Equal(t, []Selection{{"10[0].1", "bob"}}, selections)
+}
@@ -0,0 +1,98 @@
+package inspector
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+)
+
+// grpcPrefixSize is the size of the compressed flag and the length which
+// prefix a gRPC message.
+const grpcPrefixSize = 5
+
+// StreamMessage is one message of a stream. Offset is the offset of its
+// record, the prefix included, in the stream. Compressed tells whether Raw
+// was decompressed from the record.
+type StreamMessage struct {
+	Index      int
+	Offset     int
+	Raw        []byte
+	Compressed bool
+}
+
+// SplitDelimited splits a stream of messages which are each prefixed by
+// their varint length, as written by writeDelimitedTo. On error it returns
+// the messages before the error as well.
+func SplitDelimited(raw []byte) ([]StreamMessage, error) {
+	var (
+		p        = NewBuffer(raw)
+		messages []StreamMessage
+	)
+
+	for p.index < len(p.buf) {
+		offset := p.index
+		b, err := p.DecodeRawBytes(false)
+		if err != nil {
+			return messages, fmt.Errorf("inspector: [%3d] message %d err %v", offset, len(messages), err)
+		}
+		messages = append(messages, StreamMessage{
+			Index:  len(messages),
+			Offset: offset,
+			Raw:    b,
+		})
+	}
+
+	return messages, nil
+}
+
+// SplitGRPC splits a stream of gRPC length-prefixed messages: a compressed
+// flag byte and a 4-byte big-endian length before each message. Compressed
+// messages are decompressed with gzip. On error it returns the messages
+// before the error as well.
+func SplitGRPC(raw []byte) ([]StreamMessage, error) {
+	var (
+		offset   int
+		messages []StreamMessage
+	)
+
+	for offset < len(raw) {
+		if len(raw)-offset < grpcPrefixSize {
+			return messages, fmt.Errorf("inspector: [%3d] message %d err truncated prefix", offset, len(messages))
+		}
+
+		flag := raw[offset]
+		n := binary.BigEndian.Uint32(raw[offset+1 : offset+grpcPrefixSize])
+		start := offset + grpcPrefixSize
+		if uint64(n) > uint64(len(raw)-start) {
+			return messages, fmt.Errorf("inspector: [%3d] message %d err length %d exceeds %d bytes left",
+				offset, len(messages), n, len(raw)-start)
+		}
+		b := raw[start : start+int(n)]
+
+		m := StreamMessage{
+			Index:  len(messages),
+			Offset: offset,
+			Raw:    b,
+		}
+		switch flag {
+		case 0:
+		case 1:
+			r, err := gzip.NewReader(bytes.NewReader(b))
+			if err == nil {
+				m.Raw, err = readDecompressed(r)
+			}
+			if err != nil {
+				return messages, fmt.Errorf("inspector: [%3d] message %d err gzip %v", offset, len(messages), err)
+			}
+			m.Compressed = true
+		default:
+			return messages, fmt.Errorf("inspector: [%3d] message %d err unknown compressed flag %d", offset, len(messages), flag)
+		}
+
+		messages = append(messages, m)
+		offset = start + int(n)
+	}
+
+	return messages, nil
+}
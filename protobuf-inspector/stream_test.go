@@ -0,0 +1,49 @@
+package inspector
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitDelimited(t *testing.T) {
+	raw := []byte{
+		0x02, 0x08, 0x01,
+		0x00,
+		0x03, 0x0a, 0x01, 0x78,
+		0x05, 0x08,
+	}
+
+	messages, err := SplitDelimited(raw)
+	require.NotNil(t, err)
+	require.Equal(t, "inspector: [  8] message 3 err unexpected EOF", err.Error())
+	require.Equal(t, []StreamMessage{
+		{Index: 0, Offset: 0, Raw: []byte{0x08, 0x01}},
+		{Index: 1, Offset: 3, Raw: []byte{}},
+		{Index: 2, Offset: 4, Raw: []byte{0x0a, 0x01, 0x78}},
+	}, messages)
+}
+
+func TestSplitGRPC(t *testing.T) {
+	gz := bytes.NewBuffer(nil)
+	w := gzip.NewWriter(gz)
+	_, err := w.Write([]byte{0x0a, 0x01, 0x78})
+	require.Nil(t, err)
+	require.Nil(t, w.Close())
+
+	raw := []byte{0x00, 0x00, 0x00, 0x00, 0x02, 0x08, 0x01}
+	raw = append(raw, 0x01, 0x00, 0x00, 0x00, byte(gz.Len()))
+	raw = append(raw, gz.Bytes()...)
+	raw = append(raw, 0x00, 0x00, 0x00, 0x00, 0x09, 0x08)
+
+	messages, err := SplitGRPC(raw)
+	require.NotNil(t, err)
+	require.Equal(t, fmt.Sprintf("inspector: [%3d] message 2 err length 9 exceeds 1 bytes left", 12+gz.Len()), err.Error())
+	require.Equal(t, []StreamMessage{
+		{Index: 0, Offset: 0, Raw: []byte{0x08, 0x01}},
+		{Index: 1, Offset: 7, Raw: []byte{0x0a, 0x01, 0x78}, Compressed: true},
+	}, messages)
+}
@@ -0,0 +1,531 @@
+package inspector
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// The kinds of text format tokens besides punctuation
+const (
+	textEOF = -(iota + 1)
+	textIdent
+	textNumber
+	textString
+)
+
+// TextError is an error in protobuf text format input at Line and Column,
+// both counted from 1
+type TextError struct {
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *TextError) Error() string {
+	return fmt.Sprintf("inspector: %d:%d: %v", e.Line, e.Column, e.Err)
+}
+
+type textToken struct {
+	kind   rune // one of textEOF, textIdent, textNumber, textString or the punctuation
+	text   string
+	line   int
+	column int
+}
+
+// EncodeText encodes data, a message in protobuf text format like
+// `int32: 1 test2 { name: "bob" }`, as the message pkg.name. Errors are
+// TextErrors pointing to the offending input.
+func (e *Encoder) EncodeText(pkg, name string, data []byte) ([]byte, error) {
+	m, ok := e.d.Message(pkg, name)
+	if !ok {
+		return nil, fmt.Errorf("no definition found for package [%s] type [%s]", pkg, name)
+	}
+
+	toks, err := lexText(string(data))
+	if err != nil {
+		return nil, err
+	}
+	p := &textParser{e: e, toks: toks, positions: map[string]textToken{}}
+	v, err := p.parseMessage(pkg, messageFields(m), m.Name, textEOF, "")
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := e.Encode(pkg, name, v)
+	if ee, ok := err.(*EncodeError); ok {
+		t := p.position(ee.Path)
+		return nil, &TextError{Line: t.line, Column: t.column, Err: fmt.Errorf("%s: %v", ee.Path, ee.Err)}
+	}
+	return raw, err
+}
+
+type textParser struct {
+	e    *Encoder
+	toks []textToken
+	i    int
+
+	// positions are the first tokens of the values by their paths
+	positions map[string]textToken
+}
+
+func (p *textParser) peek() textToken {
+	return p.toks[p.i]
+}
+
+func (p *textParser) next() textToken {
+	t := p.toks[p.i]
+	if t.kind != textEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *textParser) errorf(t textToken, format string, args ...interface{}) error {
+	return &TextError{Line: t.line, Column: t.column, Err: fmt.Errorf(format, args...)}
+}
+
+// position returns the token of the value at path or of its closest parent
+func (p *textParser) position(path string) textToken {
+	for path != "" {
+		if t, ok := p.positions[path]; ok {
+			return t
+		}
+		i := strings.LastIndexAny(path, ".[")
+		if i < 0 {
+			break
+		}
+		path = path[:i]
+	}
+	return p.toks[0]
+}
+
+// parseMessage parses the fields of a message up to the token end
+func (p *textParser) parseMessage(pkg string, fields []*fieldInfo, name string, end rune, path string) (map[string]interface{}, error) {
+	obj := map[string]interface{}{}
+
+	for {
+		t := p.next()
+		if t.kind == end {
+			return obj, nil
+		}
+		if t.kind == textEOF {
+			return nil, p.errorf(t, "expected %q", end)
+		}
+		if t.kind != textIdent {
+			return nil, p.errorf(t, "expected field name, got %q", t.text)
+		}
+
+		var f *fieldInfo
+		for _, each := range fields {
+			if each.name == t.text {
+				f = each
+				break
+			}
+		}
+		if f == nil {
+			return nil, p.errorf(t, "unknown field %s of %s", t.text, name)
+		}
+		fpath := joinPath(path, f.name)
+		if _, ok := obj[f.name]; ok && !f.repeated && f.keyType == "" {
+			return nil, p.errorf(t, "duplicate field %s", f.name)
+		}
+
+		if p.peek().kind == ':' {
+			p.next()
+		}
+		if p.peek().kind == '[' {
+			lt := p.next()
+			if !f.repeated && f.keyType == "" {
+				return nil, p.errorf(lt, "list of non-repeated field %s", f.name)
+			}
+			for p.peek().kind != ']' {
+				if err := p.parseField(pkg, f, obj, fpath); err != nil {
+					return nil, err
+				}
+				if p.peek().kind != ',' {
+					break
+				}
+				p.next()
+				// unlike the fields of messages, lists take no trailing comma
+				if t := p.peek(); t.kind == ']' {
+					return nil, p.errorf(t, "expected value after \",\", got \"]\"")
+				}
+			}
+			if t := p.next(); t.kind != ']' {
+				return nil, p.errorf(t, "expected \"]\", got %q", t.text)
+			}
+		} else if err := p.parseField(pkg, f, obj, fpath); err != nil {
+			return nil, err
+		}
+
+		if k := p.peek().kind; k == ',' || k == ';' {
+			p.next()
+		}
+	}
+}
+
+// parseField parses one value of the field f and adds it to obj
+func (p *textParser) parseField(pkg string, f *fieldInfo, obj map[string]interface{}, path string) error {
+	if f.keyType != "" {
+		t := p.peek()
+		entry, err := p.parseNested(pkg, []*fieldInfo{
+			{name: "key", typ: f.keyType, number: 1},
+			{name: "value", typ: f.typ, number: 2},
+		}, f.name, path)
+		if err != nil {
+			return err
+		}
+
+		// an entry without key has the default key
+		key := defaultKey(f.keyType)
+		switch k := entry["key"].(type) {
+		case string:
+			key = k
+		case json.Number:
+			key = string(k)
+		case bool:
+			key = strconv.FormatBool(k)
+		}
+		m, _ := obj[f.name].(map[string]interface{})
+		if m == nil {
+			m = map[string]interface{}{}
+			obj[f.name] = m
+		}
+		epath := fmt.Sprintf("%s[%s]", path, strconv.Quote(key))
+		p.positions[epath] = t
+		if v, ok := entry["value"]; ok {
+			m[key] = v
+		} else {
+			m[key] = nil
+		}
+		return nil
+	}
+
+	if f.repeated {
+		list, _ := obj[f.name].([]interface{})
+		v, err := p.parseValue(pkg, f.typ, fmt.Sprintf("%s[%d]", path, len(list)))
+		if err != nil {
+			return err
+		}
+		obj[f.name] = append(list, v)
+		return nil
+	}
+
+	v, err := p.parseValue(pkg, f.typ, path)
+	if err != nil {
+		return err
+	}
+	obj[f.name] = v
+	return nil
+}
+
+// parseNested parses a message value in braces or angle brackets
+func (p *textParser) parseNested(pkg string, fields []*fieldInfo, name, path string) (map[string]interface{}, error) {
+	t := p.next()
+	switch t.kind {
+	case '{':
+		return p.parseMessage(pkg, fields, name, '}', path)
+	case '<':
+		return p.parseMessage(pkg, fields, name, '>', path)
+	}
+	return nil, p.errorf(t, "expected message, got %q", t.text)
+}
+
+// parseValue parses a value of type typ
+func (p *textParser) parseValue(pkg, typ, path string) (interface{}, error) {
+	t := p.peek()
+	p.positions[path] = t
+
+	if _, ok := scalarWires[typ]; ok {
+		return p.parseScalar(typ)
+	}
+	if mpkg, m, ok := p.e.d.resolveMessage(pkg, typ); ok {
+		return p.parseNested(mpkg, messageFields(m), m.Name, path)
+	}
+	if _, ok := p.e.d.resolveEnum(pkg, typ); ok {
+		if t.kind == textIdent {
+			p.next()
+			return t.text, nil
+		}
+		return p.parseNumber()
+	}
+	return nil, p.errorf(t, "unresolved type %s", typ)
+}
+
+func (p *textParser) parseScalar(typ string) (interface{}, error) {
+	t := p.peek()
+
+	switch typ {
+	case "string", "bytes":
+		if t.kind != textString {
+			return nil, p.errorf(t, "expected string, got %q", t.text)
+		}
+		var b []byte
+		for p.peek().kind == textString {
+			b = append(b, p.next().text...)
+		}
+		if typ == "bytes" {
+			return b, nil
+		}
+		if !utf8.Valid(b) {
+			return nil, p.errorf(t, "invalid UTF-8 in string")
+		}
+		return string(b), nil
+
+	case "bool":
+		p.next()
+		switch t.text {
+		case "true", "True", "t", "1":
+			return true, nil
+		case "false", "False", "f", "0":
+			return false, nil
+		}
+		return nil, p.errorf(t, "expected bool, got %q", t.text)
+
+	case "float", "double":
+		sign := ""
+		if t.kind == '-' {
+			p.next()
+			sign = "-"
+			t = p.peek()
+		}
+		if t.kind == textIdent {
+			p.next()
+			switch strings.ToLower(t.text) {
+			case "inf", "infinity":
+				return sign + "Infinity", nil
+			case "nan":
+				return "NaN", nil
+			}
+			return nil, p.errorf(t, "expected number, got %q", t.text)
+		}
+		v, err := p.parseNumber()
+		if err != nil {
+			return nil, err
+		}
+		return json.Number(sign + strings.TrimRight(string(v.(json.Number)), "fF")), nil
+	}
+
+	return p.parseNumber()
+}
+
+// parseNumber parses a number with an optional minus sign
+func (p *textParser) parseNumber() (interface{}, error) {
+	t := p.next()
+	sign := ""
+	if t.kind == '-' {
+		sign = "-"
+		t = p.next()
+	}
+	if t.kind != textNumber {
+		return nil, p.errorf(t, "expected number, got %q", t.text)
+	}
+	return json.Number(sign + decimalText(t.text)), nil
+}
+
+// decimalText returns the hex like 0x10 and octal like 010 integers of the
+// text format in decimal, which the encoder takes like JSON, and s otherwise
+func decimalText(s string) string {
+	var (
+		n   uint64
+		err error
+	)
+	switch {
+	case strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X"):
+		n, err = strconv.ParseUint(s[2:], 16, 64)
+	case len(s) > 1 && s[0] == '0' && strings.Trim(s, "01234567") == "":
+		n, err = strconv.ParseUint(s[1:], 8, 64)
+	default:
+		return s
+	}
+	if err != nil {
+		return s
+	}
+	return strconv.FormatUint(n, 10)
+}
+
+// lexText splits protobuf text format into tokens
+func lexText(s string) ([]textToken, error) {
+	var (
+		toks      []textToken
+		line, col = 1, 1
+		i         int
+	)
+	advance := func(n int) {
+		for _, r := range s[i : i+n] {
+			if r == '\n' {
+				line++
+				col = 1
+			} else {
+				col++
+			}
+		}
+		i += n
+	}
+
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\v' || c == '\f':
+			advance(1)
+
+		case c == '#':
+			n := strings.IndexByte(s[i:], '\n')
+			if n < 0 {
+				n = len(s) - i
+			}
+			advance(n)
+
+		case isIdentStart(c):
+			n := 1
+			for i+n < len(s) && (isIdentStart(s[i+n]) || isDigit(s[i+n]) || s[i+n] == '.') {
+				n++
+			}
+			toks = append(toks, textToken{kind: textIdent, text: s[i : i+n], line: line, column: col})
+			advance(n)
+
+		case isDigit(c) || (c == '.' && i+1 < len(s) && isDigit(s[i+1])):
+			n := 1
+			for i+n < len(s) {
+				d := s[i+n]
+				exp := (d == '-' || d == '+') && (s[i+n-1] == 'e' || s[i+n-1] == 'E') &&
+					!strings.HasPrefix(s[i:], "0x") && !strings.HasPrefix(s[i:], "0X")
+				if !isIdentStart(d) && !isDigit(d) && d != '.' && !exp {
+					break
+				}
+				n++
+			}
+			toks = append(toks, textToken{kind: textNumber, text: s[i : i+n], line: line, column: col})
+			advance(n)
+
+		case c == '"' || c == '\'':
+			text, n, err := unquoteText(s[i:])
+			if err != nil {
+				return nil, &TextError{Line: line, Column: col, Err: err}
+			}
+			toks = append(toks, textToken{kind: textString, text: text, line: line, column: col})
+			advance(n)
+
+		case strings.IndexByte("{}<>[]:,;-", c) >= 0:
+			toks = append(toks, textToken{kind: rune(c), text: string(c), line: line, column: col})
+			advance(1)
+
+		default:
+			r, _ := utf8.DecodeRuneInString(s[i:])
+			return nil, &TextError{Line: line, Column: col, Err: fmt.Errorf("unexpected %q", r)}
+		}
+	}
+
+	toks = append(toks, textToken{kind: textEOF, text: "EOF", line: line, column: col})
+	return toks, nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// unquoteText unquotes the string literal which s starts with, returning
+// its bytes and the length of the literal
+func unquoteText(s string) (string, int, error) {
+	quote := s[0]
+	var b []byte
+
+	i := 1
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == quote:
+			return string(b), i + 1, nil
+
+		case c == '\n':
+			return "", 0, fmt.Errorf("newline in string")
+
+		case c != '\\':
+			b = append(b, c)
+			i++
+
+		case i+1 >= len(s):
+			return "", 0, fmt.Errorf("unterminated string")
+
+		default:
+			e, n, err := unescape(s[i+1:])
+			if err != nil {
+				return "", 0, err
+			}
+			b = append(b, e...)
+			i += 1 + n
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+// unescape decodes the escape sequence which s starts with after the
+// backslash, like n, x0a, 012 or u00e9, returning its bytes and the length
+// of the sequence
+func unescape(s string) ([]byte, int, error) {
+	e := s[0]
+	switch e {
+	case 'a':
+		return []byte{'\a'}, 1, nil
+	case 'b':
+		return []byte{'\b'}, 1, nil
+	case 'f':
+		return []byte{'\f'}, 1, nil
+	case 'n':
+		return []byte{'\n'}, 1, nil
+	case 'r':
+		return []byte{'\r'}, 1, nil
+	case 't':
+		return []byte{'\t'}, 1, nil
+	case 'v':
+		return []byte{'\v'}, 1, nil
+	case '\\', '\'', '"', '?':
+		return []byte{e}, 1, nil
+	case 'x', 'X':
+		n := 0
+		for n < 2 && 1+n < len(s) && isHexDigit(s[1+n]) {
+			n++
+		}
+		if n == 0 {
+			return nil, 0, fmt.Errorf("invalid escape \\%c", e)
+		}
+		x, _ := strconv.ParseUint(s[1:1+n], 16, 8)
+		return []byte{byte(x)}, 1 + n, nil
+	case 'u', 'U':
+		n := 4
+		if e == 'U' {
+			n = 8
+		}
+		if 1+n > len(s) {
+			return nil, 0, fmt.Errorf("invalid escape \\%c", e)
+		}
+		x, err := strconv.ParseUint(s[1:1+n], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(x)) {
+			return nil, 0, fmt.Errorf("invalid escape \\%c%s", e, s[1:1+n])
+		}
+		return []byte(string(rune(x))), 1 + n, nil
+	}
+	if e < '0' || e > '7' {
+		return nil, 0, fmt.Errorf("invalid escape \\%c", e)
+	}
+	n := 1
+	for n < 3 && n < len(s) && '0' <= s[n] && s[n] <= '7' {
+		n++
+	}
+	x, _ := strconv.ParseUint(s[:n], 8, 16)
+	if x > 0xff {
+		return nil, 0, fmt.Errorf("invalid escape \\%s", s[:n])
+	}
+	return []byte{byte(x)}, n, nil
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || ('a' <= c && c <= 'f') || ('A' <= c && c <= 'F')
+}
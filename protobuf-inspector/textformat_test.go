@@ -0,0 +1,126 @@
+package inspector
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	testv1 "github.com/detailyang/pb-inspector-go/proto/go/proto/test/v1"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeText(t *testing.T) {
+	in := NewInspector()
+	require.Nil(t, in.ReadSchemaFromFile("../proto/test/v1/test.proto"))
+
+	raw, err := in.EncodeText("test.v1", "Test", []byte(`
+# a comment
+int32: -1
+int64: 0x10
+uint32: 010
+float: 1.5f
+double: -inf
+bool: t
+bytes: "\x01\002" 'x'
+string: "h\u00e9"
+test2 < name: "bob" >
+`))
+	require.Nil(t, err)
+
+	test := testv1.Test{
+		Int32:   -1,
+		Int64:   16,
+		Uint32:  8,
+		Float:   1.5,
+		Double:  math.Inf(-1),
+		Bool:    true,
+		Bytes:   []byte{0x01, 0x02, 'x'},
+		String_: "hé",
+		Test2:   &testv1.Test2{Name: "bob"},
+	}
+	expected, err := proto.Marshal(&test)
+	require.Nil(t, err)
+	require.Equal(t, expected, raw)
+
+	tests := []struct {
+		text string
+		err  string
+	}{
+		{"int32: 1\ntest2 {\n  name: 1\n}", `inspector: 3:9: expected string, got "1"`},
+		{"int32: 2147483648", "inspector: 1:8: int32: invalid int32 2147483648"},
+		{"int32: 1_0", "inspector: 1:8: int32: invalid int32 1_0"},
+		{"int32: 0x80000000", "inspector: 1:8: int32: invalid int32 2147483648"},
+		{"test2 { nam: \"x\" }", "inspector: 1:9: unknown field nam of Test2"},
+		{"int32: 1 int32: 2", "inspector: 1:10: duplicate field int32"},
+		{"test2 { name: \"x\"", `inspector: 1:18: expected '}'`},
+		{"string: \"\\q\"", `inspector: 1:9: invalid escape \q`},
+	}
+	for _, test := range tests {
+		_, err := in.EncodeText("test.v1", "Test", []byte(test.text))
+		require.NotNil(t, err, test.text)
+		require.Equal(t, test.err, err.Error())
+	}
+}
+
+func TestEncodeTextCollections(t *testing.T) {
+	schema := `
+syntax = "proto3";
+
+package envelope.v1;
+
+enum Kind {
+  UNKNOWN = 0;
+  ITEM = 1;
+}
+
+message Envelope {
+  repeated int32 ids = 1;
+  repeated Item items = 2;
+  map<string, int64> attrs = 3;
+  Kind kind = 4;
+  map<int32, Item> byid = 5;
+}
+
+message Item {
+  int32 id = 1;
+}
+`
+	in := NewInspector()
+	require.Nil(t, in.ReadSchemaFromReader("envelope.proto", strings.NewReader(schema)))
+
+	raw, err := in.EncodeText("envelope.v1", "Envelope", []byte(`
+ids: [1, 150]
+items { id: 1 }
+items: [{ id: 2 }]
+attrs { key: "b" value: 2 }
+attrs { key: "a" value: 1 }
+kind: ITEM
+`))
+	require.Nil(t, err)
+	require.Equal(t, []byte{
+		0x0a, 0x03, 0x01, 0x96, 0x01, // ids
+		0x12, 0x02, 0x08, 0x01, // items
+		0x12, 0x02, 0x08, 0x02, // items
+		0x1a, 0x05, 0x0a, 0x01, 0x61, 0x10, 0x01, // attrs a
+		0x1a, 0x05, 0x0a, 0x01, 0x62, 0x10, 0x02, // attrs b
+		0x20, 0x01, // kind
+	}, raw)
+
+	_, err = in.EncodeText("envelope.v1", "Envelope", []byte("items { id: 1 }\nitems { id: 99999999999 }"))
+	require.Equal(t, "inspector: 2:13: items[1].id: invalid int32 99999999999", err.Error())
+	_, err = in.EncodeText("envelope.v1", "Envelope", []byte("attrs { key: \"k\" value: x }"))
+	require.Equal(t, `inspector: 1:25: expected number, got "x"`, err.Error())
+	_, err = in.EncodeText("envelope.v1", "Envelope", []byte("kind: OTHER"))
+	require.Equal(t, "inspector: 1:7: kind: unknown value OTHER of enum Kind", err.Error())
+	_, err = in.EncodeText("envelope.v1", "Envelope", []byte("ids: [1,]"))
+	require.Equal(t, `inspector: 1:9: expected value after ",", got "]"`, err.Error())
+
+	// map entries without key have the default key
+	raw, err = in.EncodeText("envelope.v1", "Envelope", []byte("byid { value { id: 1 } } attrs { value: 1 }"))
+	require.Nil(t, err)
+	require.Equal(t, []byte{
+		0x1a, 0x04, 0x0a, 0x00, 0x10, 0x01, // attrs ""
+		0x2a, 0x06, 0x08, 0x00, 0x12, 0x02, 0x08, 0x01, // byid 0
+	}, raw)
+}
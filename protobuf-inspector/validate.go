@@ -0,0 +1,211 @@
+package inspector
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"unicode/utf8"
+
+	pp "github.com/emicklei/proto"
+	pb "github.com/golang/protobuf/proto"
+)
+
+// The kinds of an Issue
+const (
+	IssueUnknownField    = "unknown field"
+	IssueWireType        = "wrong wire type"
+	IssueInvalidUTF8     = "invalid utf-8"
+	IssueEnumRange       = "enum out of range"
+	IssueMissingRequired = "missing required field"
+	IssueTrailingGarbage = "trailing garbage"
+)
+
+// Issue is a problem of a message found by Validate: the field at Path,
+// like test2.name or items[1], whose tag starts at Offset
+type Issue struct {
+	Offset  int    `json:"offset"`
+	Path    string `json:"path"`
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// Validate decodes raw strictly as the message pkg.name of d and returns
+// every problem it finds, in the order of offsets. The message is valid if
+// there is none.
+func Validate(d *Definition, pkg, name string, raw []byte) ([]Issue, error) {
+	m, ok := d.Message(pkg, name)
+	if !ok {
+		return nil, fmt.Errorf("no definition found for package [%s] type [%s]", pkg, name)
+	}
+	v := &validator{d: d}
+	v.message(pkg, m, raw, 0, "", false)
+	return v.issues, nil
+}
+
+// WriteIssues writes issues as text lines to w
+func WriteIssues(w io.Writer, issues []Issue) {
+	for _, issue := range issues {
+		path := issue.Path
+		if path == "" {
+			path = "-"
+		}
+		fmt.Fprintf(w, "%3d: %s: %s: %s\n", issue.Offset, path, issue.Kind, issue.Message)
+	}
+}
+
+type validator struct {
+	d      *Definition
+	issues []Issue
+}
+
+func (v *validator) add(offset int, path, kind, format string, args ...interface{}) {
+	v.issues = append(v.issues, Issue{Offset: offset, Path: path, Kind: kind, Message: fmt.Sprintf(format, args...)})
+}
+
+// message validates raw, a message m of package pkg which starts at base
+// of the outermost message. The key and value of a map entry take the path
+// of the entry.
+func (v *validator) message(pkg string, m *pp.Message, raw []byte, base int, path string, entry bool) {
+	fields := map[int]*fieldInfo{}
+	for _, f := range messageFields(m) {
+		fields[f.number] = f
+	}
+	groups := map[int]*pp.Group{}
+	for _, each := range m.Elements {
+		if g, ok := each.(*pp.Group); ok {
+			groups[g.Sequence] = g
+		}
+	}
+	seen := map[int]bool{}
+	counts := map[int]int{}
+
+	p := NewBuffer(raw)
+	for p.index < len(raw) {
+		s, err := p.scanField()
+		if err == nil && s.number == 0 {
+			err = fmt.Errorf("field number 0")
+		}
+		if err != nil {
+			v.add(base+s.start, path, IssueTrailingGarbage, "%d bytes which are not a field: %v", len(raw)-s.start, err)
+			break
+		}
+
+		if g, ok := groups[s.number]; ok {
+			seen[g.Sequence] = true
+			v.group(pkg, g, raw, s, base, path, counts)
+			continue
+		}
+
+		f, ok := fields[s.number]
+		if !ok {
+			v.add(base+s.start, joinPath(path, strconv.Itoa(s.number)), IssueUnknownField, "field %d of wire type %d is not in %s", s.number, s.wire, m.Name)
+			continue
+		}
+		seen[f.number] = true
+
+		fpath := joinPath(path, f.name)
+		if entry {
+			fpath = path
+		}
+		if f.repeated || f.keyType != "" {
+			fpath = joinPath(path, pathSegment{name: f.name, selector: selectIndex, index: counts[f.number]}.String())
+			counts[f.number]++
+		}
+		if f.keyType != "" && s.wire == pb.WireBytes {
+			if key, ok := entryKey(f.keyType, raw[s.data:s.end]); ok {
+				fpath = joinPath(path, mapKeySegment(f.name, key))
+			}
+		}
+		v.field(pkg, f, raw, s, base, fpath)
+	}
+
+	for _, each := range m.Elements {
+		if f, ok := each.(*pp.NormalField); ok && f.Required && !seen[f.Sequence] {
+			v.add(base+len(raw), joinPath(path, f.Name), IssueMissingRequired, "required field %d of %s is missing", f.Sequence, m.Name)
+		}
+	}
+}
+
+// group validates the fields of the group g whose byte range is s, counts
+// being the number of each field seen so far in the message of g
+func (v *validator) group(pkg string, g *pp.Group, raw []byte, s fieldSpan, base int, path string, counts map[int]int) {
+	gpath := joinPath(path, groupFieldName(g))
+	if g.Repeated {
+		gpath = joinPath(path, pathSegment{name: groupFieldName(g), selector: selectIndex, index: counts[g.Sequence]}.String())
+		counts[g.Sequence]++
+	}
+	if s.wire != pb.WireStartGroup {
+		v.add(base+s.start, gpath, IssueWireType, "wire type %d of group %s which is %d", s.wire, g.Name, pb.WireStartGroup)
+		return
+	}
+	end := s.end - pb.SizeVarint(uint64(s.number)<<3|pb.WireEndGroup)
+	v.message(pkg, groupMessage(g), raw[s.data:end], base+s.data, gpath, false)
+}
+
+// field validates the value of f whose byte range is s
+func (v *validator) field(pkg string, f *fieldInfo, raw []byte, s fieldSpan, base int, path string) {
+	wire, known := fieldWire(v.d, pkg, f)
+	if !known {
+		return
+	}
+
+	packed := f.repeated && f.keyType == "" && s.wire == pb.WireBytes && wire != pb.WireBytes
+	if s.wire != wire && !packed {
+		v.add(base+s.start, path, IssueWireType, "wire type %d of %s which is %d", s.wire, fieldType(f), wire)
+		return
+	}
+
+	start := base + s.start
+	switch {
+	case f.keyType != "":
+		v.message(pkg, mapEntryMessage(f), raw[s.data:s.end], base+s.data, path, true)
+
+	case f.typ == "string":
+		if !utf8.Valid(raw[s.data:s.end]) {
+			v.add(start, path, IssueInvalidUTF8, "string %q is not valid utf-8", raw[s.data:s.end])
+		}
+
+	case wire == pb.WireBytes && f.typ != "bytes":
+		if mpkg, m, ok := v.d.resolveMessage(pkg, f.typ); ok {
+			v.message(mpkg, m, raw[s.data:s.end], base+s.data, path, false)
+		}
+
+	case packed:
+		values, ok := packedValues(raw[s.data:s.end], wire)
+		if !ok {
+			v.add(start, path, IssueWireType, "packed %s is malformed", fieldType(f))
+			return
+		}
+		v.enum(pkg, f, values, start, path)
+
+	case wire == pb.WireVarint:
+		x, _ := NewBuffer(raw[s.start:s.end]).skipTag().DecodeVarint()
+		v.enum(pkg, f, []uint64{x}, start, path)
+	}
+}
+
+// enum checks that values of f are declared if it is an enum
+func (v *validator) enum(pkg string, f *fieldInfo, values []uint64, offset int, path string) {
+	e, ok := v.d.resolveEnum(pkg, f.typ)
+	if !ok {
+		return
+	}
+
+	declared := map[int32]bool{}
+	for _, each := range e.Elements {
+		if ef, ok := each.(*pp.EnumField); ok {
+			declared[int32(ef.Integer)] = true
+		}
+	}
+	for _, x := range values {
+		// negative values are sign-extended to 64 bits
+		if n := int64(x); n < math.MinInt32 || n > math.MaxInt32 {
+			v.add(offset, path, IssueEnumRange, "value %d is out of the int32 range of enum %s", x, e.Name)
+			continue
+		}
+		if !declared[int32(x)] {
+			v.add(offset, path, IssueEnumRange, "value %d is not in enum %s", int32(x), e.Name)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package inspector
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	testv1 "github.com/detailyang/pb-inspector-go/proto/go/proto/test/v1"
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	in := NewInspector()
+	require.Nil(t, in.ReadSchemaFromFile("../proto/test/v1/test.proto"))
+
+	raw, err := proto.Marshal(&testv1.Test{Int32: 1, String_: "hello", Test2: &testv1.Test2{Name: "bob"}})
+	require.Nil(t, err)
+	issues, err := in.Validate("test.v1", "Test", raw)
+	require.Nil(t, err)
+	require.Empty(t, issues)
+
+	raw = []byte{
+		0x12, 0x01, 0x78, // int64 as bytes
+		0x4a, 0x02, 0xff, 0xfe, // string
+		0x52, 0x04, 0x0a, 0x01, 0x80, 0x18, // test2 with an invalid name and an unknown field
+		0x58, 0x01, // unknown
+		0x0f, 0x01, // garbage
+	}
+	issues, err = in.Validate("test.v1", "Test", raw)
+	require.Nil(t, err)
+	require.Equal(t, []Issue{
+		{Offset: 0, Path: "int64", Kind: IssueWireType, Message: "wire type 2 of int64 which is 0"},
+		{Offset: 3, Path: "string", Kind: IssueInvalidUTF8, Message: `string "\xff\xfe" is not valid utf-8`},
+		{Offset: 9, Path: "test2.name", Kind: IssueInvalidUTF8, Message: `string "\x80" is not valid utf-8`},
+		{Offset: 12, Path: "test2", Kind: IssueTrailingGarbage, Message: "1 bytes which are not a field: unexpected EOF"},
+		{Offset: 13, Path: "11", Kind: IssueUnknownField, Message: "field 11 of wire type 0 is not in Test"},
+		{Offset: 15, Path: "", Kind: IssueTrailingGarbage, Message: "2 bytes which are not a field: unexpected wire type 7"},
+	}, issues)
+
+	w := bytes.NewBuffer(nil)
+	WriteIssues(w, issues[4:])
+	require.Equal(t, " 13: 11: unknown field: field 11 of wire type 0 is not in Test\n"+
+		" 15: -: trailing garbage: 2 bytes which are not a field: unexpected wire type 7\n", w.String())
+
+	_, err = in.Validate("test.v1", "Nope", raw)
+	require.NotNil(t, err)
+}
+
+func TestValidateCollections(t *testing.T) {
+	schema := `
+syntax = "proto2";
+
+package order.v1;
+
+message Order {
+  required int32 id = 1;
+  repeated Status statuses = 2 [packed = true];
+  repeated Item items = 3;
+  map<string, Item> attrs = 4;
+  optional Status status = 5;
+}
+
+message Item {
+  required string name = 1;
+}
+
+enum Status {
+  OPEN = 1;
+  CLOSED = 2;
+}
+`
+	in := NewInspector()
+	require.Nil(t, in.ReadSchemaFromReader("order.proto", strings.NewReader(schema)))
+
+	raw := []byte{
+		0x12, 0x03, 0x01, 0x07, 0x02, // statuses
+		0x1a, 0x03, 0x0a, 0x01, 0x61, // items
+		0x1a, 0x00, // items without name
+		0x22, 0x05, 0x0a, 0x01, 0x6b, 0x12, 0x00, // attrs["k"] without name
+		0x28, 0x09, // status
+		0x28, 0x81, 0x80, 0x80, 0x80, 0x10, // status 1<<32 + 1
+		0x28, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, // status -1
+	}
+	issues, err := in.Validate("order.v1", "Order", raw)
+	require.Nil(t, err)
+	require.Equal(t, []Issue{
+		{Offset: 0, Path: "statuses[0]", Kind: IssueEnumRange, Message: "value 7 is not in enum Status"},
+		{Offset: 12, Path: "items[1].name", Kind: IssueMissingRequired, Message: "required field 1 of Item is missing"},
+		{Offset: 19, Path: `attrs["k"].name`, Kind: IssueMissingRequired, Message: "required field 1 of Item is missing"},
+		{Offset: 19, Path: "status", Kind: IssueEnumRange, Message: "value 9 is not in enum Status"},
+		{Offset: 21, Path: "status", Kind: IssueEnumRange, Message: "value 4294967297 is out of the int32 range of enum Status"},
+		{Offset: 27, Path: "status", Kind: IssueEnumRange, Message: "value -1 is not in enum Status"},
+		{Offset: 38, Path: "id", Kind: IssueMissingRequired, Message: "required field 1 of Order is missing"},
+	}, issues)
+}
@@ -0,0 +1,163 @@
+package inspector
+
+import (
+	"fmt"
+
+	pb "github.com/golang/protobuf/proto"
+)
+
+// fieldSpan is the byte range of one field of a message: its tag starts at
+// Start, its payload at Data for length-delimited fields and groups and it
+// ends at End
+type fieldSpan struct {
+	start  int
+	data   int
+	end    int
+	number int
+	wire   uint64
+}
+
+// scanFields returns the byte ranges of the fields of raw
+func scanFields(raw []byte) ([]fieldSpan, error) {
+	var spans []fieldSpan
+
+	p := NewBuffer(raw)
+	for p.index < len(raw) {
+		s, err := p.scanField()
+		if _, ok := err.(tagError); ok {
+			return nil, fmt.Errorf("[%3d] %v", s.start, err)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("[%3d] t=%3d err %v", s.start, s.number, err)
+		}
+		spans = append(spans, s)
+	}
+	return spans, nil
+}
+
+// tagError is the error of a field whose tag cannot be read
+type tagError struct {
+	err error
+}
+
+func (e tagError) Error() string {
+	return "tag err " + e.err.Error()
+}
+
+// scanField reads the next field of p and returns its byte range
+func (p *Buffer) scanField() (fieldSpan, error) {
+	s := fieldSpan{start: p.index}
+	op, err := p.DecodeVarint()
+	if err != nil {
+		return s, tagError{err}
+	}
+	s.number, s.wire = int(op>>3), op&7
+
+	switch s.wire {
+	case pb.WireVarint:
+		_, err = p.DecodeVarint()
+	case pb.WireFixed64:
+		_, err = p.DecodeFixed64()
+	case pb.WireFixed32:
+		_, err = p.DecodeFixed32()
+	case pb.WireBytes:
+		var n uint64
+		n, err = p.DecodeVarint()
+		s.data = p.index
+		if err == nil && n > uint64(len(p.buf)-p.index) {
+			err = fmt.Errorf("length %d exceeds %d bytes left", n, len(p.buf)-p.index)
+		}
+		if err == nil {
+			p.index += int(n)
+		}
+	case pb.WireStartGroup:
+		s.data = p.index
+		_, err = p.decodeRawFields(0, &RawField{Tag: uint64(s.number), Wire: s.wire})
+		if err == nil && len(p.diagnostics) > 0 {
+			err = fmt.Errorf("%s", p.diagnostics[0].Message)
+		}
+	default:
+		err = fmt.Errorf("unexpected wire type %d", s.wire)
+	}
+	if err != nil {
+		return s, err
+	}
+
+	s.end = p.index
+	return s, nil
+}
+
+// skipTag skips the tag at the start of p
+func (p *Buffer) skipTag() *Buffer {
+	p.DecodeVarint()
+	return p
+}
+
+// packedValues decodes the packed values of wire type wire, and false if b
+// is not made of such values only
+func packedValues(b []byte, wire uint64) ([]uint64, bool) {
+	switch wire {
+	case pb.WireVarint:
+		// unlike packedVarints, which guesses, empty and padded varints are valid
+		var vs []uint64
+		p := NewBuffer(b)
+		for p.index < len(b) {
+			x, err := p.DecodeVarint()
+			if err != nil {
+				return nil, false
+			}
+			vs = append(vs, x)
+		}
+		return vs, true
+	case pb.WireFixed32:
+		if len(b)%4 != 0 {
+			return nil, false
+		}
+		return packedFixed(b, 4), true
+	case pb.WireFixed64:
+		if len(b)%8 != 0 {
+			return nil, false
+		}
+		return packedFixed(b, 8), true
+	}
+	return nil, false
+}
+
+// entryKey returns the key of the map entry b as formatted by the decoder,
+// the last one if the key field occurs more than once
+func entryKey(keyType string, b []byte) (string, bool) {
+	var (
+		key   string
+		found bool
+	)
+	p := NewBuffer(b)
+	for p.index < len(b) {
+		s, err := p.scanField()
+		if err != nil {
+			return "", false
+		}
+		if s.number != 1 {
+			continue
+		}
+		if keyType == "string" && s.wire == pb.WireBytes {
+			key, found = string(b[s.data:s.end]), true
+			continue
+		}
+		if s.wire != pb.WireVarint {
+			return "", false
+		}
+		x, _ := NewBuffer(b[s.start:s.end]).skipTag().DecodeVarint()
+		switch keyType {
+		case "uint32", "uint64":
+			key = fmt.Sprintf("%d (%s)", x, keyType)
+		case "sint32", "sint64":
+			key = fmt.Sprintf("%d (%s)", int64(x>>1)^-int64(x&1), keyType)
+		case "bool":
+			key = fmt.Sprintf("%v (%s)", x != 0, keyType)
+		default:
+			key = fmt.Sprintf("%d (%s)", int64(x), keyType)
+		}
+		found = true
+	}
+	return key, found
+}